@@ -0,0 +1,82 @@
+package relay
+
+import (
+	"encoding/binary"
+	"sync"
+	"testing"
+)
+
+// fakeBoard emulates a relay board behind the Transporter interface.
+type fakeBoard struct {
+	mu    sync.Mutex
+	state uint32
+	// drop silently ignores frames with the given function code.
+	drop map[byte]bool
+	// frames records every function code sent to the board.
+	frames []byte
+	// err fails every frame while set.
+	err error
+}
+
+func (b *fakeBoard) Send(aduRequest []byte) (aduResponse []byte, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.err != nil {
+		return nil, b.err
+	}
+	code := aduRequest[2]
+	b.frames = append(b.frames, code)
+	data := aduRequest[3:7]
+	mask := binary.BigEndian.Uint32(data)
+	bit := uint32(1) << (data[3] - 1)
+	if !b.drop[code] {
+		switch code {
+		case RequestOffOne, RequestOffOneNil:
+			b.state &^= bit
+		case RequestOnOne, RequestOnOneNil, RequestOnPoint, RequestOnPointNil:
+			b.state |= bit
+		case RequestOffPoint, RequestOffPointNil:
+			b.state &^= bit
+		case RequestFlipOne, RequestFlipOneNil:
+			b.state ^= bit
+		case RequestRunCMD, RequestRunCMDNil:
+			b.state = mask
+		case RequestOffGroup, RequestOffGroupNil:
+			b.state &^= mask
+		case RequestOnGroup, RequestOnGroupNil:
+			b.state |= mask
+		case RequestFlipGroup, RequestFlipGroupNil:
+			b.state ^= mask
+		}
+	}
+	if calculateRelayResponseLength(code) == 0 {
+		return nil, nil
+	}
+	aduResponse = make([]byte, DataLength)
+	aduResponse[0] = ResponseHeader
+	aduResponse[1] = aduRequest[1]
+	aduResponse[2] = code
+	binary.BigEndian.PutUint32(aduResponse[3:7], b.state)
+	aduResponse[7] = Sign(aduResponse)
+	return aduResponse, nil
+}
+
+func (b *fakeBoard) fail(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.err = err
+}
+
+func (b *fakeBoard) get() uint32 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+func newTestClient(t *testing.T, length byte) (*Client, *fakeBoard) {
+	t.Helper()
+	board := &fakeBoard{}
+	c := NewClient(NewHandler(""), length)
+	c.transporter = board
+	return c, board
+}
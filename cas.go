@@ -0,0 +1,40 @@
+package relay
+
+import "fmt"
+
+// MismatchError is returned by SwitchIf when the board was not in the
+// expected state. Actual is the status read from the board, BIT0 is channel 1.
+type MismatchError struct {
+	Expected uint32
+	Actual   uint32
+}
+
+func (e *MismatchError) Error() string {
+	return fmt.Sprintf("继电器状态不符: 期望 %#x, 实际 %#x", e.Expected, e.Actual)
+}
+
+// SwitchIf sets all channels to next if the board is currently in the
+// expected state. The status is read from the board under the client lock,
+// so no other command of this client can interleave. Otherwise nothing is
+// switched and a *MismatchError reports the actual state. Only the bits of
+// the channels of the board are compared.
+func (c *Client) SwitchIf(expected, next uint32) (uint32, error) {
+	data := maskData(next)
+	c.acquire(RequestRunCMD, data)
+	defer c.unlock()
+	actual, err := c.update(c.transact(RequestReadStatus, []byte{0, 0, 0, 0}))
+	if err != nil {
+		return 0, err
+	}
+	if actual&c.full() != expected&c.full() {
+		return actual, &MismatchError{Expected: expected, Actual: actual}
+	}
+	status, err := c.commandLocked(RequestRunCMD, data)
+	if err != nil {
+		return 0, err
+	}
+	if status&c.full() != next&c.full() {
+		return status, ErrReturnResult
+	}
+	return status, nil
+}
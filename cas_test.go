@@ -0,0 +1,33 @@
+package relay
+
+import "testing"
+
+func TestClient_SwitchIf(t *testing.T) {
+	c, board := newTestClient(t, DefaultBranchesLength)
+	status, err := c.SwitchIf(0, 0x05)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != 0x05 || board.get() != 0x05 {
+		t.Fatalf("status %#x board %#x, want 0x5", status, board.get())
+	}
+
+	// another process flips channel 2 behind our back
+	board.state |= 0x02
+	status, err = c.SwitchIf(0x05, 0)
+	e, ok := err.(*MismatchError)
+	if !ok || e.Actual != 0x07 || status != 0x07 {
+		t.Fatalf("status %#x err %v, want mismatch", status, err)
+	}
+	if board.get() != 0x07 || c.GetStats()[1] != 1 {
+		t.Fatalf("board %#x cache %v", board.get(), c.GetStats())
+	}
+
+	// channels beyond the board are not compared
+	if _, err := c.SwitchIf(0xff07, 0x01); err != nil {
+		t.Fatal(err)
+	}
+	if board.get() != 0x01 {
+		t.Fatalf("board %#x, want 0x1", board.get())
+	}
+}
@@ -0,0 +1,81 @@
+package relay
+
+import "encoding/binary"
+
+// Channel is a relay channel number counting from 1. Channel 1 is BIT0 of the
+// last data byte in every frame, channel 32 is BIT7 of the first one. Every
+// Client method taking a Channel validates it against the client length.
+type Channel byte
+
+// Channel returns channel n of the client, counting from 1.
+func (c *Client) Channel(n int) (Channel, error) {
+	if n < 1 || n > int(c.length) {
+		return 0, ErrBranchesLength
+	}
+	return Channel(n), nil
+}
+
+// Channels returns the channels n of the client, counting from 1.
+func (c *Client) Channels(n ...int) ([]Channel, error) {
+	chs := make([]Channel, len(n))
+	for k, v := range n {
+		ch, err := c.Channel(v)
+		if err != nil {
+			return nil, err
+		}
+		chs[k] = ch
+	}
+	return chs, nil
+}
+
+// Length returns the number of channels of the client.
+func (c *Client) Length() byte {
+	return c.length
+}
+
+// Mask returns the 32 bit mask of the channels, BIT0 is channel 1.
+func Mask(chs ...Channel) uint32 {
+	mask := uint32(0)
+	for _, ch := range chs {
+		mask |= ch.bit()
+	}
+	return mask
+}
+
+// bit is the mask of the channel, zero for channel 0.
+func (ch Channel) bit() uint32 {
+	if ch == 0 {
+		return 0
+	}
+	return 1 << (ch - 1)
+}
+
+// check returns ErrBranchesLength if a channel is out of range.
+func (c *Client) check(chs ...Channel) error {
+	for _, ch := range chs {
+		if ch < 1 || byte(ch) > c.length {
+			return ErrBranchesLength
+		}
+	}
+	return nil
+}
+
+// mask validates the channels and returns their mask.
+func (c *Client) mask(chs ...Channel) (uint32, error) {
+	if err := c.check(chs...); err != nil {
+		return 0, err
+	}
+	return Mask(chs...), nil
+}
+
+// channelData is the data of a single channel command.
+func channelData(ch Channel) []byte {
+	return []byte{0, 0, 0, byte(ch)}
+}
+
+// maskData is the data of a group or RunCMD command.
+func maskData(mask uint32) []byte {
+	data := make([]byte, 4)
+	binary.BigEndian.PutUint32(data, mask)
+	return data
+}
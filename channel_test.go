@@ -0,0 +1,52 @@
+package relay
+
+import "testing"
+
+func TestClient_Channel(t *testing.T) {
+	c, _ := newTestClient(t, DefaultBranchesLength)
+	for _, n := range []int{0, 9, -1} {
+		if _, err := c.Channel(n); err != ErrBranchesLength {
+			t.Fatalf("channel %d: err %v, want %v", n, err, ErrBranchesLength)
+		}
+	}
+	chs, err := c.Channels(1, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if Mask(chs...) != 0x81 {
+		t.Fatalf("mask %#x, want %#x", Mask(chs...), 0x81)
+	}
+	if err := c.On(9); err != ErrBranchesLength {
+		t.Fatalf("err %v, want %v", err, ErrBranchesLength)
+	}
+	if err := c.OnChannels(1, 0); err != ErrBranchesLength {
+		t.Fatalf("err %v, want %v", err, ErrBranchesLength)
+	}
+}
+
+func TestClient_DeprecatedIndexing(t *testing.T) {
+	c, board := newTestClient(t, DefaultBranchesLength)
+	steps := []struct {
+		name string
+		do   func() error
+		want uint32
+	}{
+		{"OnOne(1)", func() error { return c.OnOne(1) }, 0x01},
+		{"OnOneNil(1)", func() error { return c.OnOneNil(1) }, 0x03},
+		{"OnGroup(2, 3)", func() error { return c.OnGroup(2, 3) }, 0x0f},
+		{"OffGroupNil(0)", func() error { return c.OffGroupNil(0) }, 0x0e},
+		{"FlipOneNil(7)", func() error { return c.FlipOneNil(7) }, 0x8e},
+		{"OffOne(8)", func() error { return c.OffOne(8) }, 0x0e},
+	}
+	for _, step := range steps {
+		if err := step.do(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if board.get() != step.want {
+			t.Fatalf("%s: board %#x, want %#x", step.name, board.get(), step.want)
+		}
+	}
+	if err := c.OnGroup(8); err != ErrBranchesLength {
+		t.Fatalf("err %v, want %v", err, ErrBranchesLength)
+	}
+}
@@ -2,7 +2,6 @@ package relay
 
 import (
 	"encoding/binary"
-	"github.com/zing-dev/go-bit-bytes/bin"
 	"github.com/zing-dev/go-bit-bytes/bit"
 	"sync"
 	"time"
@@ -14,8 +13,52 @@ type Client struct {
 	transporter Transporter
 	length      byte
 
-	from byte
-	stat []uint16
+	from   byte
+	stat   []uint16
+	pulses map[Channel]*Pulse
+	clock  Clock
+
+	handler func(Event)
+	events  []Event
+
+	store StateStore
+	saved []byte
+	meta  map[Channel]ChannelMeta
+
+	safe   SafePolicy
+	lost   time.Time
+	closed bool
+
+	interlocks []Interlock
+	offAt      [MaxBranchesLength]time.Time
+	onAt       [MaxBranchesLength]time.Time
+	maxOn      [MaxBranchesLength]time.Duration
+	limits     [MaxBranchesLength]Timer
+
+	cycles [MaxBranchesLength]uint64
+	onTime [MaxBranchesLength]time.Duration
+	rated  [MaxBranchesLength]WearLimit
+
+	rates   [MaxBranchesLength]RateLimit
+	changes [MaxBranchesLength][]time.Time
+
+	latched int32
+	audit   []LatchRecord
+
+	lockouts map[Channel]Lockout
+	actor    string
+	exempt   bool
+	// restoring is set while the cache is initialised on Restore
+	restoring bool
+
+	holds       map[Channel][]*Hold
+	holdTimeout time.Duration
+	leases      map[*Lease]bool
+	watchdogs   map[*Watchdog]bool
+
+	inverted uint32
+	scenes   map[string]Scene
+	jobs     map[string]time.Time
 	sync.Mutex
 }
 
@@ -33,6 +76,8 @@ func NewClient(handler *ClientHandler, length byte) *Client {
 		transporter: handler,
 		length:      length,
 		stat:        stat,
+		pulses:      make(map[Channel]*Pulse),
+		clock:       SystemClock,
 	}
 }
 
@@ -50,13 +95,29 @@ func (c *Client) SetStatusFrom(from byte) {
 func (c *Client) GetStats() []uint16 {
 	c.Lock()
 	defer c.Unlock()
-	return c.stat
+	return append([]uint16(nil), c.stat...)
 }
 
 //send 发送有返回数据
 func (c *Client) send(code byte, data []byte) ([]byte, error) {
 	c.Lock()
 	defer c.Unlock()
+	return c.transact(code, data)
+}
+
+// transact 发送有返回数据,调用者需持有锁
+func (c *Client) transact(code byte, data []byte) ([]byte, error) {
+	if c.closed {
+		return nil, ErrClientClosed
+	}
+	if err := c.reconnect(); err != nil {
+		return nil, err
+	}
+	return c.roundTrip(code, data)
+}
+
+// roundTrip 发送一帧并读取返回,调用者需持有锁
+func (c *Client) roundTrip(code byte, data []byte) ([]byte, error) {
 	if c.packager == nil || c.transporter == nil {
 		return nil, ErrPackagerNil
 	}
@@ -68,6 +129,7 @@ func (c *Client) send(code byte, data []byte) ([]byte, error) {
 		return nil, err
 	}
 	adu, err = c.transporter.Send(adu)
+	c.track(err)
 	if err != nil {
 		return nil, err
 	}
@@ -78,52 +140,105 @@ func (c *Client) send(code byte, data []byte) ([]byte, error) {
 	return pdu.Data, nil
 }
 
+// command 发送有返回的控制命令,取消受影响路数的点动并按返回状态更新缓存
+func (c *Client) command(code byte, data []byte) (uint32, error) {
+	return c.commandAs("", code, data)
+}
+
+// commandAs 以 actor 的身份发送控制命令,用于检查锁定的路数
+func (c *Client) commandAs(actor string, code byte, data []byte) (uint32, error) {
+	c.acquire(code, data)
+	defer c.unlock()
+	c.actor = actor
+	defer func() {
+		c.actor = ""
+	}()
+	return c.commandLocked(code, data)
+}
+
+// commandLocked 同 command,调用者需持有锁
+func (c *Client) commandLocked(code byte, data []byte) (uint32, error) {
+	if err := c.admit(code, data); err != nil {
+		return 0, err
+	}
+	c.cancelPulses(c.affected(code, data))
+	return c.exchange(code, data)
+}
+
+// exchange 发送命令并按返回的状态更新缓存,调用者需持有锁
+func (c *Client) exchange(code byte, data []byte) (uint32, error) {
+	return c.update(c.transact(code, data))
+}
+
+// update 按返回的状态更新缓存,调用者需持有锁
+func (c *Client) update(data []byte, err error) (uint32, error) {
+	if err != nil {
+		return 0, err
+	}
+	if len(data) != 4 {
+		return 0, ErrReturnResult
+	}
+	status := binary.BigEndian.Uint32(data)
+	c.setStat(status)
+	return status, nil
+}
+
+// affected 命令涉及的路数掩码,BIT0 代表第一路
+func (c *Client) affected(code byte, data []byte) uint32 {
+	switch code {
+	case RequestReadStatus:
+		return 0
+	case RequestRunCMD, RequestRunCMDNil:
+		return c.full()
+	case RequestOffGroup, RequestOnGroup, RequestFlipGroup,
+		RequestOffGroupNil, RequestOnGroupNil, RequestFlipGroupNil:
+		return binary.BigEndian.Uint32(data)
+	}
+	return Channel(data[3]).bit()
+}
+
 //单个继电器路数处理
-func (c *Client) one(i, code, result byte) error {
-	if i < 1 || i > c.length {
-		return ErrBranchesLength
+func (c *Client) one(ch Channel, code, result byte) error {
+	if err := c.check(ch); err != nil {
+		return err
 	}
-	data, err := c.send(code, []byte{0, 0, 0, i})
+	status, err := c.command(code, channelData(ch))
 	if err != nil {
 		return err
 	}
 	//继电器输出板或者输入检测板：数据区域 4 个字节，每个字节 8 位，共 32 位。代表 32 路的状
 	//态。最后一个字节的第 0 位代表第 1 路，依次类推。
-	if result == 0 && byte(binary.BigEndian.Uint32(data)&(1<<(i-1))) == result {
-		return nil
-	} else if result == 1 && byte(binary.BigEndian.Uint32(data)&(1<<(i-1))>>(i-1)) == result {
-		return nil
-	} else if result == 2 {
+	if result == 2 || byte(status>>(ch-1)&1) == result {
 		return nil
 	}
 	return ErrReturnResult
 }
 
-// OffOne 断开某路
-func (c *Client) OffOne(i byte) error {
-	return c.one(i, RequestOffOne, 0)
+// Off 断开某路
+func (c *Client) Off(ch Channel) error {
+	return c.one(ch, RequestOffOne, 0)
 }
 
-// OnOne 闭合某路
-func (c *Client) OnOne(i byte) error {
-	return c.one(i, RequestOnOne, 1)
+// On 闭合某路
+func (c *Client) On(ch Channel) error {
+	return c.one(ch, RequestOnOne, 1)
 }
 
-// FlipOne 翻转某路
-func (c *Client) FlipOne(i byte) error {
-	return c.one(i, RequestFlipOne, 2)
+// Flip 翻转某路
+func (c *Client) Flip(ch Channel) error {
+	return c.one(ch, RequestFlipOne, 2)
 }
 
-// StatusOne 某路继电器状态
-func (c *Client) StatusOne(i byte) (byte, error) {
-	if i < 1 || i > c.length {
-		return 0, ErrBranchesLength
+// StatusOf 某路继电器状态
+func (c *Client) StatusOf(ch Channel) (byte, error) {
+	if err := c.check(ch); err != nil {
+		return 0, err
 	}
 	status, err := c.status()
 	if err != nil {
 		return 0, err
 	}
-	return status[i-1], nil
+	return status[ch-1], nil
 }
 
 // Status 继电器状态
@@ -138,23 +253,48 @@ func (c *Client) Status() ([]byte, error) {
 //最大继电器路数状态 MaxBranchesLength
 func (c *Client) status() ([]byte, error) {
 	status := make([]byte, MaxBranchesLength)
-	data, err := c.send(RequestReadStatus, []byte{0, 0, 0, 0})
+	mask, err := c.readStatus()
 	if err != nil {
 		return nil, err
 	}
-	if len(data) != 4 {
-		return nil, ErrReturnResult
-	}
-	for k, v := range data {
-		for i := 0; i < 8; i++ {
-			status[(3-k)*8+i] = v & (1 << i) >> i
-		}
+	for i := range status {
+		status[i] = byte(mask >> i & 1)
 	}
 	return status, nil
 }
 
+// readStatus 读取继电器状态,BIT0 代表第一路
+func (c *Client) readStatus() (uint32, error) {
+	c.Lock()
+	defer c.Unlock()
+	return c.readStatusLocked()
+}
+
+// readStatusLocked 同 readStatus,调用者需持有锁
+func (c *Client) readStatusLocked() (uint32, error) {
+	data, err := c.transact(RequestReadStatus, []byte{0, 0, 0, 0})
+	if err != nil {
+		return 0, err
+	}
+	if len(data) != 4 {
+		return 0, ErrReturnResult
+	}
+	return binary.BigEndian.Uint32(data), nil
+}
+
 //sendNil 发送无返回数据
 func (c *Client) sendNil(code byte, data []byte) error {
+	c.acquire(code, data)
+	defer c.unlock()
+	if c.closed {
+		return ErrClientClosed
+	}
+	if err := c.admit(code, data); err != nil {
+		return err
+	}
+	if err := c.reconnect(); err != nil {
+		return err
+	}
 	if c.packager == nil || c.transporter == nil {
 		return ErrPackagerNil
 	}
@@ -166,96 +306,49 @@ func (c *Client) sendNil(code byte, data []byte) error {
 		return err
 	}
 	adu, err = c.transporter.Send(adu)
+	c.track(err)
 	c.onNil(code, data)
 	return err
 }
 
 //组操作
-func (c *Client) group(branches ...byte) ([]byte, error) {
-	min, max := byte(0), byte(0)
-	for _, v := range branches {
-		if min > v {
-			min = v
-		}
-
-		if max < v {
-			max = v
-		}
-	}
-	if min < 0 || max >= c.length {
-		return nil, ErrBranchesLength
+func (c *Client) group(code byte, chs ...Channel) (uint32, error) {
+	mask, err := c.mask(chs...)
+	if err != nil {
+		return 0, err
 	}
+	return c.command(code, maskData(mask))
+}
 
-	origin := make([]byte, MaxBranchesLength)
-	for key := range origin {
-		for _, val := range branches {
-			if byte(key) == val {
-				origin[key] = 1
-			}
-		}
-	}
-	status := make([]byte, 4)
-	for i := 0; i < 4; i++ {
-		sum := byte(0)
-		for k, v := range origin[(3-i)*8 : (4-i)*8] {
-			sum += v << k
-		}
-		status[i] = sum
-	}
-	return status, nil
+// OffChannels 断开组
+func (c *Client) OffChannels(chs ...Channel) error {
+	_, err := c.group(RequestOffGroup, chs...)
+	return err
 }
 
-// OffGroup 断开组
-func (c *Client) OffGroup(i ...byte) error {
-	group, err := c.group(i...)
-	if err != nil {
-		return err
-	}
-	_, err = c.send(RequestOffGroup, group)
+// OnChannels 闭合组
+func (c *Client) OnChannels(chs ...Channel) error {
+	_, err := c.group(RequestOnGroup, chs...)
 	return err
 }
 
-// OnGroup 闭合组
-func (c *Client) OnGroup(i ...byte) error {
-	group, err := c.group(i...)
-	if err != nil {
-		return err
-	}
-	_, err = c.send(RequestOnGroup, group)
+// FlipChannels 组翻转
+func (c *Client) FlipChannels(chs ...Channel) error {
+	_, err := c.group(RequestFlipGroup, chs...)
 	return err
 }
 
-// FlipGroup 组翻转
-func (c *Client) FlipGroup(i ...byte) error {
-	group, err := c.group(i...)
+// SetAll 命令执行 按32位掩码设置所有继电器,BIT0 代表第一路,1 吸合 0 断开
+// 返回继电器执行后的状态并同步缓存
+func (c *Client) SetAll(mask uint32) error {
+	status, err := c.command(RequestRunCMD, maskData(mask))
 	if err != nil {
 		return err
 	}
-	_, err = c.send(RequestFlipGroup, group)
-	return err
-}
-
-//点动操作
-//时间毫秒
-func (c *Client) point(code, i byte, time int) error {
-	i++
-	if i <= 0 || i > c.length {
-		return ErrBranchesLength
+	if status&c.full() != mask&c.full() {
+		return ErrReturnResult
 	}
-	data := make([]byte, 4)
-	binary.BigEndian.PutUint32(data, uint32(time))
-	data, err := c.send(code, []byte{data[1], data[2], data[3], i})
-	return err
-}
-
-// OffPoint 点动断开某路
-func (c *Client) OffPoint(i byte, t int) error {
-	return c.point(RequestOffPoint, i, t)
-}
-
-// OnPoint 点动闭合某路
-func (c *Client) OnPoint(i byte, t int) error {
-	return c.point(RequestOnPoint, i, t)
+	return nil
 }
 
 // OffAll 断开所有
@@ -269,143 +362,181 @@ func (c *Client) OnAll() error {
 }
 
 //某路操作无返回数据
-func (c *Client) oneNil(i, code byte) error {
-	i++
-	if i <= 0 || i > c.length {
-		return ErrBranchesLength
+func (c *Client) oneNil(ch Channel, code byte) error {
+	if err := c.check(ch); err != nil {
+		return err
 	}
-	return c.sendNil(code, []byte{0, 0, 0, i})
+	return c.sendNil(code, channelData(ch))
 }
 
-// FlipOneNil 翻转某路
-func (c *Client) FlipOneNil(i byte) error {
-	return c.oneNil(i, RequestFlipOneNil)
+// FlipNil 翻转某路
+func (c *Client) FlipNil(ch Channel) error {
+	return c.oneNil(ch, RequestFlipOneNil)
 }
 
-// OffOneNil 断开某路
-func (c *Client) OffOneNil(i byte) error {
-	return c.oneNil(i, RequestOffOneNil)
+// OffNil 断开某路
+func (c *Client) OffNil(ch Channel) error {
+	return c.oneNil(ch, RequestOffOneNil)
 }
 
-// OnOneNil 吸合某路
-func (c *Client) OnOneNil(i byte) error {
-	return c.oneNil(i+1, RequestOnOneNil)
+// OnNil 吸合某路
+func (c *Client) OnNil(ch Channel) error {
+	return c.oneNil(ch, RequestOnOneNil)
 }
 
-// OffGroupNil 断开组
-func (c *Client) OffGroupNil(i ...byte) error {
-	group, err := c.group(i...)
+//组操作无返回数据
+func (c *Client) groupNil(code byte, chs ...Channel) error {
+	mask, err := c.mask(chs...)
 	if err != nil {
 		return err
 	}
-	return c.sendNil(RequestOffGroupNil, group)
+	return c.sendNil(code, maskData(mask))
 }
 
-// OnGroupNil 吸合组
-func (c *Client) OnGroupNil(i ...byte) error {
-	group, err := c.group(i...)
-	if err != nil {
-		return err
+// OffChannelsNil 断开组
+func (c *Client) OffChannelsNil(chs ...Channel) error {
+	return c.groupNil(RequestOffGroupNil, chs...)
+}
+
+// OnChannelsNil 吸合组
+func (c *Client) OnChannelsNil(chs ...Channel) error {
+	return c.groupNil(RequestOnGroupNil, chs...)
+}
+
+// FlipChannelsNil 翻转组
+func (c *Client) FlipChannelsNil(chs ...Channel) error {
+	return c.groupNil(RequestFlipGroupNil, chs...)
+}
+
+//当不需要操作的返回值,继电器的状态值有自己控制,调用者需持有锁
+func (c *Client) onNil(code byte, data []byte) {
+	c.cancelPulses(c.affected(code, data))
+	if code == RequestOnPointNil || code == RequestOffPointNil {
+		d := pointTime(data)
+		p := c.newPulse(code, Channel(data[3]), d)
+		c.pulses[p.Channel] = p
+		p.timer = c.clock.AfterFunc(pointDelay(d), p.expire)
+	}
+	c.setStat(apply(code, data, c.cached()))
+}
+
+// admit 发送前检查命令执行后的状态是否允许,调用者需持有锁
+func (c *Client) admit(code byte, data []byte) error {
+	from := c.cached()
+	to := apply(code, data, from) & c.full()
+	//点动断开中且不受本命令影响的路视为吸合
+	resuming := c.resuming() &^ c.affected(code, data)
+	from, to = from|resuming, to|resuming
+	if code == RequestOffPoint || code == RequestOffPointNil {
+		//点动断开结束后该路重新吸合
+		if err := c.guard(from, from|Channel(data[3]).bit()); err != nil {
+			return err
+		}
 	}
-	return c.sendNil(RequestOnGroupNil, group)
+	return c.guard(from, to)
 }
 
-// FlipGroupNil 翻转组
-func (c *Client) FlipGroupNil(i ...byte) error {
-	group, err := c.group(i...)
-	if err != nil {
+// guard 检查状态从 from 变为 to 是否允许,调用者需持有锁
+func (c *Client) guard(from, to uint32) error {
+	if err := c.checkLatch(from, to); err != nil {
+		return err
+	}
+	if err := c.checkLockouts(from, to); err != nil {
 		return err
 	}
-	return c.sendNil(RequestFlipGroupNil, group)
+	if err := c.checkInterlocks(from, to); err != nil {
+		return err
+	}
+	return c.checkRates(from, to)
 }
 
-//点动处理无返回数据
-//0 <= i && i < c.length time 毫秒
-func (c *Client) pointNil(code, i byte, time int) error {
-	i++
-	if i <= 0 || i > c.length {
-		return ErrBranchesLength
+// apply 命令执行后的继电器状态,BIT0 代表第一路
+func apply(code byte, data []byte, state uint32) uint32 {
+	mask := binary.BigEndian.Uint32(data)
+	one := Channel(data[3]).bit()
+	switch code {
+	case RequestOffOne, RequestOffOneNil, RequestOffPoint, RequestOffPointNil:
+		return state &^ one
+	case RequestOnOne, RequestOnOneNil, RequestOnPoint, RequestOnPointNil:
+		return state | one
+	case RequestFlipOne, RequestFlipOneNil:
+		return state ^ one
+	case RequestRunCMD, RequestRunCMDNil:
+		return mask
+	//数据区域共4个字节，每个字节8位，共32位。
+	//最多代表对32路的操作，1代表断开 0代表保持原来状态。最后一个字节的第0位(BIT0)代表第一路，依次类推。
+	case RequestOffGroup, RequestOffGroupNil:
+		return state &^ mask
+	case RequestOnGroup, RequestOnGroupNil:
+		return state | mask
+	case RequestFlipGroup, RequestFlipGroupNil:
+		return state ^ mask
 	}
-	data := make([]byte, 4)
-	binary.BigEndian.PutUint32(data, uint32(time))
-	return c.sendNil(code, []byte{data[1], data[2], data[3], i})
+	return state
 }
 
-// OnPointNil 点动闭合
-func (c *Client) OnPointNil(i byte, t int) error {
-	return c.pointNil(RequestOnPointNil, i, t)
+// pointTime 点动数据前三个字节的毫秒数
+func pointTime(data []byte) time.Duration {
+	return time.Millisecond * time.Duration(bit.ToUint32(append([]byte{0}, data[:3]...)))
 }
 
-// OffPointNil 点动断开
-func (c *Client) OffPointNil(i byte, t int) error {
-	return c.pointNil(RequestOffPointNil, i, t)
+// pointDelay 点动结束前提前10毫秒更新缓存
+func pointDelay(d time.Duration) time.Duration {
+	if d <= pointAdvance {
+		return 0
+	}
+	return d - pointAdvance
 }
 
-//当不需要操作的返回值,继电器的状态值有自己控制
-func (c *Client) onNil(code byte, data []byte) {
-	c.Lock()
-	defer c.Unlock()
-	switch code {
-	case RequestOffOneNil:
-		c.stat[data[3]-1] = 0
-	case RequestOnPointNil:
-		time.AfterFunc(time.Millisecond*time.Duration(bit.ToUint32(append([]byte{0}, data[:3]...))-10), func() {
-			c.Lock()
-			c.stat[data[3]-1] = 0
-			defer c.Unlock()
-		})
-	case RequestOffPointNil:
-		time.AfterFunc(time.Millisecond*time.Duration(bit.ToUint32(append([]byte{0}, data[:3]...))-10), func() {
-			c.Lock()
-			c.stat[data[3]-1] = 1
-			defer c.Unlock()
-		})
-	case RequestOffGroupNil, RequestOnGroupNil:
-		d := bin.Revert(bin.FromInt(bit.ToInt(data)))
-		//数据区域共4个字节，每个字节8位，共32位。
-		//最多代表对32路的操作，1代表断开 0代表保持原来状态。最后一个字节的第0位(BIT0)代表第一路，依次类推。
-		if code == RequestOffGroupNil {
-			for i := range c.stat {
-				if c.stat[i] == 1 && d[i] == 1 {
-					c.stat[i] = 0
-				}
-			}
-		} else {
-			//数据区域共4个字节，每个字节8位，共32位。
-			//最多代表对32路的操作，1 代表吸合 0代表保持原来状态。最后一个字节的第0位(BIT0)代表第一路，依次类推。
-			for i := range c.stat {
-				if c.stat[i] == 0 && d[i] == 1 {
-					c.stat[i] = 1
-				}
-			}
-		}
-	case RequestOnOneNil:
-		c.stat[data[3]-1] = 1
-	case RequestFlipOneNil:
-		s := c.stat[data[3]-1]
-		if s == 0 {
-			c.stat[data[3]-1] = 1
-		} else {
-			c.stat[data[3]-1] = 0
+// unlock 保存变化的状态,释放锁后依次通知持有锁期间产生的事件
+func (c *Client) unlock() {
+	c.persist()
+	events, handler := c.events, c.handler
+	c.events = nil
+	c.Unlock()
+	if handler == nil {
+		return
+	}
+	for _, e := range events {
+		handler(e)
+	}
+}
+
+// full 当前路数对应的掩码
+func (c *Client) full() uint32 {
+	return uint32(1<<c.length - 1)
+}
+
+// cached 缓存状态的32位掩码,调用者需持有锁
+func (c *Client) cached() uint32 {
+	mask := uint32(0)
+	for i, v := range c.stat {
+		if v != 0 {
+			mask |= 1 << i
 		}
-	case RequestFlipGroupNil:
-		d := bin.Revert(bin.FromInt(bit.ToInt(data)))
-		for i := range c.stat {
-			if d[i] == 1 {
-				if c.stat[i] == 0 {
-					c.stat[i] = 1
-				} else {
-					c.stat[i] = 0
-				}
-			}
+	}
+	return mask
+}
+
+// setStat 按32位状态更新缓存并记录每路的切换时间,调用者需持有锁
+func (c *Client) setStat(status uint32) {
+	now := c.clock.Now()
+	for i := range c.stat {
+		v := uint16(status >> i & 1)
+		if c.stat[i] == v {
+			continue
 		}
-	case RequestRunCMDNil:
-		if data[0] == 0 {
-			c.stat = make([]uint16, c.length)
+		if v == 0 {
+			c.offAt[i] = now
+			c.onTime[i] += now.Sub(c.onAt[i])
 		} else {
-			for i := range c.stat {
-				c.stat[i] = 1
+			c.onAt[i] = now
+			if !c.restoring {
+				c.cycle(Channel(i + 1))
 			}
 		}
+		c.stat[i] = v
+		c.record(Channel(i+1), now)
+		c.armMaxOn(Channel(i + 1))
 	}
 }
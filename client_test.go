@@ -143,3 +143,26 @@ func TestPointNil(t *testing.T) {
 	fmt.Println(client.OnPoint(2, 2000))
 	fmt.Println(client.OnPoint(8, 2000))
 }
+
+func TestClient_SetAll(t *testing.T) {
+	c, board := newTestClient(t, DefaultBranchesLength)
+	if err := c.SetAll(0xa5); err != nil {
+		t.Fatal(err)
+	}
+	if board.get() != 0xa5 {
+		t.Fatalf("board state %#x, want %#x", board.get(), 0xa5)
+	}
+	want := []uint16{1, 0, 1, 0, 0, 1, 0, 1}
+	for i, v := range c.GetStats() {
+		if v != want[i] {
+			t.Fatalf("cache %v, want %v", c.GetStats(), want)
+		}
+	}
+	board.drop = map[byte]bool{RequestRunCMD: true}
+	if err := c.SetAll(0x0f); err != ErrReturnResult {
+		t.Fatalf("err %v, want %v", err, ErrReturnResult)
+	}
+	if c.GetStats()[1] != 0 || c.GetStats()[2] != 1 {
+		t.Fatalf("cache not synced with board: %v", c.GetStats())
+	}
+}
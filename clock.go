@@ -0,0 +1,45 @@
+package relay
+
+import "time"
+
+// Clock provides the time functions used by Client and the serial
+// transporter, so that timing can be simulated in tests.
+type Clock interface {
+	Now() time.Time
+	AfterFunc(d time.Duration, f func()) Timer
+	Sleep(d time.Duration)
+}
+
+// Timer is the subset of *time.Timer returned by Clock.AfterFunc.
+type Timer interface {
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// SystemClock is the Clock backed by the time package.
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+func (systemClock) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
+
+// SetClock replaces the clock of the client and, when the client was created
+// with a ClientHandler, of its serial transporter.
+func (c *Client) SetClock(clock Clock) {
+	c.Lock()
+	defer c.Unlock()
+	c.clock = clock
+	if handler, ok := c.transporter.(*ClientHandler); ok {
+		handler.Clock = clock
+	}
+}
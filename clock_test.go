@@ -0,0 +1,141 @@
+package relay
+
+import (
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a Clock whose time only moves when Advance or Sleep is called.
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	clock  *fakeClock
+	when   time.Time
+	f      func()
+	active bool
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) AfterFunc(d time.Duration, f func()) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTimer{clock: c, when: c.now.Add(d), f: f, active: true}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+func (c *fakeClock) Sleep(d time.Duration) {
+	c.Advance(d)
+}
+
+// Advance moves the clock forward by d, running due timers in order.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	end := c.now.Add(d)
+	for {
+		sort.SliceStable(c.timers, func(i, j int) bool {
+			return c.timers[i].when.Before(c.timers[j].when)
+		})
+		var next *fakeTimer
+		for _, t := range c.timers {
+			if t.active && !t.when.After(end) {
+				next = t
+				break
+			}
+		}
+		if next == nil {
+			break
+		}
+		next.active = false
+		c.now = next.when
+		c.mu.Unlock()
+		next.f()
+		c.mu.Lock()
+	}
+	c.now = end
+	c.mu.Unlock()
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	active := t.active
+	t.active = false
+	return active
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	active := t.active
+	t.active = true
+	t.when = t.clock.now.Add(d)
+	return active
+}
+
+func TestSerialCloseIdleClock(t *testing.T) {
+	clock := newFakeClock()
+	port := &nopCloser{}
+	s := serialPort{
+		port:        port,
+		IdleTimeout: time.Minute,
+		Clock:       clock,
+	}
+	s.lastActivity = clock.Now()
+	s.startCloseTimer()
+
+	clock.Advance(59 * time.Second)
+	if port.closed {
+		t.Fatal("serial port closed before idle timeout")
+	}
+	clock.Advance(time.Second)
+	if !port.closed || s.port != nil {
+		t.Fatalf("serial port is not closed when inactivity: %+v", port)
+	}
+}
+
+func TestClient_PulseChained(t *testing.T) {
+	c, board := newTestClient(t, DefaultBranchesLength)
+	clock := newFakeClock()
+	c.SetClock(clock)
+
+	p, err := c.Pulse(1, MaxPointDuration+time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(MaxPointDuration - pulseOverlap - time.Millisecond)
+	if len(board.frames) != 1 {
+		t.Fatalf("frames %x, want one point command", board.frames)
+	}
+	clock.Advance(time.Millisecond)
+	if len(board.frames) != 2 || board.frames[1] != RequestOnPoint {
+		t.Fatalf("frames %x, want chained point command", board.frames)
+	}
+	if r := p.Remaining(); r != time.Hour+pulseOverlap {
+		t.Fatalf("remaining %v, want %v", r, time.Hour+pulseOverlap)
+	}
+	clock.Advance(time.Hour + pulseOverlap)
+	select {
+	case <-p.Done():
+	default:
+		t.Fatal("pulse did not end")
+	}
+	if c.GetStats()[0] != 0 {
+		t.Fatalf("channel 1 still closed in cache: %v", c.GetStats())
+	}
+}
@@ -25,10 +25,8 @@ func main() {
 	time.Sleep(time.Second)
 	_ = client.OnAll()
 	time.Sleep(time.Second)
-	i := 1
-	for i < 8 {
-		_ = client.OffOne(byte(i))
-		i++
+	for ch := relay.Channel(1); ch < 8; ch++ {
+		_ = client.Off(ch)
 	}
 	time.Sleep(time.Second)
 }
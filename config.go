@@ -0,0 +1,289 @@
+package relay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+var (
+	ErrUnknownChannel  = errors.New("未定义的通道名称")
+	ErrUnknownSequence = errors.New("未定义的序列")
+)
+
+// Config describes the relay boards of an installation and the names of
+// their channels, see LoadConfig:
+//
+//  {
+//    "boards": [{
+//      "name": "pumps", "port": "/dev/ttyUSB0", "slave_id": 1, "channels": 8,
+//      "named": [
+//        {"name": "pump.main", "channel": 7, "label": "主泵", "load": "motor"},
+//        {"name": "valve.drain", "channel": 3, "normally_closed": true}
+//      ]
+//    }]
+//  }
+//
+// Sequences run on one of the boards, see Sequence.
+type Config struct {
+	Boards    []BoardConfig `json:"boards"`
+	Sequences []Sequence    `json:"sequences,omitempty"`
+}
+
+// BoardConfig is a relay board on a serial port.
+type BoardConfig struct {
+	Name    string `json:"name"`
+	Port    string `json:"port"`
+	SlaveID byte   `json:"slave_id"`
+	// BaudRate defaults to 9600.
+	BaudRate int `json:"baud_rate,omitempty"`
+	// Channels is the number of channels of the board, DefaultBranchesLength
+	// if zero.
+	Channels byte            `json:"channels,omitempty"`
+	Named    []ChannelConfig `json:"named,omitempty"`
+}
+
+// ChannelConfig names a channel of a board and describes its load.
+type ChannelConfig struct {
+	// Name is unique across all boards, e.g. "pump.main".
+	Name    string  `json:"name"`
+	Channel Channel `json:"channel"`
+	Label   string  `json:"label,omitempty"`
+	// Load is the kind of load, e.g. "motor", "lamp" or "valve".
+	Load string `json:"load,omitempty"`
+	// NormallyClosed is set when the load is wired to the normally closed
+	// contact, so it runs while the relay is released.
+	NormallyClosed bool `json:"normally_closed,omitempty"`
+	// Inverted is set when the load logic is inverted by the installation,
+	// for example a valve that closes when powered.
+	Inverted bool `json:"inverted,omitempty"`
+}
+
+// LoadConfig reads a JSON Config from the file.
+func LoadConfig(path string) (*Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadConfig(f)
+}
+
+// ReadConfig decodes a JSON Config and validates it.
+func ReadConfig(r io.Reader) (*Config, error) {
+	cfg := &Config{}
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(cfg); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Validate checks that board, channel and sequence names are unique, that
+// boards sharing a port have distinct slave ids and the same baud rate, that
+// every named channel exists on its board and that the sequences are valid.
+func (cfg *Config) Validate() error {
+	boards := make(map[string]bool)
+	names := make(map[string]bool)
+	ports := make(map[string]BoardConfig)
+	for _, b := range cfg.Boards {
+		if b.Name == "" || boards[b.Name] {
+			return fmt.Errorf("config: board name %q is empty or not unique", b.Name)
+		}
+		boards[b.Name] = true
+		if b.Port == "" {
+			return fmt.Errorf("config: board %q has no port", b.Name)
+		}
+		if other, ok := ports[b.Port]; ok {
+			if other.SlaveID == b.SlaveID {
+				return fmt.Errorf("config: boards %q and %q share slave id %d on %s", other.Name, b.Name, b.SlaveID, b.Port)
+			}
+			if other.baudRate() != b.baudRate() {
+				return fmt.Errorf("config: boards %q and %q use different baud rates on %s", other.Name, b.Name, b.Port)
+			}
+		}
+		ports[b.Port] = b
+		if b.Channels > MaxBranchesLength {
+			return fmt.Errorf("config: board %q has more than %d channels", b.Name, MaxBranchesLength)
+		}
+		length := b.length()
+		for _, ch := range b.Named {
+			if ch.Name == "" || names[ch.Name] {
+				return fmt.Errorf("config: channel name %q is empty or not unique", ch.Name)
+			}
+			names[ch.Name] = true
+			if ch.Channel < 1 || byte(ch.Channel) > length {
+				return fmt.Errorf("config: channel %q: %w", ch.Name, ErrBranchesLength)
+			}
+		}
+	}
+	sequences := make(map[string]bool)
+	for _, seq := range cfg.Sequences {
+		if seq.Name == "" || sequences[seq.Name] {
+			return fmt.Errorf("config: sequence name %q is empty or not unique", seq.Name)
+		}
+		sequences[seq.Name] = true
+		if !boards[seq.Board] {
+			return fmt.Errorf("config: sequence %q runs on unknown board %q", seq.Name, seq.Board)
+		}
+		if err := seq.Validate(); err != nil {
+			return fmt.Errorf("config: sequence %q: %w", seq.Name, err)
+		}
+	}
+	return nil
+}
+
+// baudRate is the baud rate of the board's port.
+func (b BoardConfig) baudRate() int {
+	if b.BaudRate == 0 {
+		return 9600
+	}
+	return b.BaudRate
+}
+
+// length is the channel count of the client created for the board.
+func (b BoardConfig) length() byte {
+	if b.Channels < DefaultBranchesLength {
+		return DefaultBranchesLength
+	}
+	return b.Channels
+}
+
+// meta is the ChannelMeta stored with the state of the client.
+func (ch ChannelConfig) meta() ChannelMeta {
+	meta := ChannelMeta{Label: ch.Label, Attributes: map[string]string{"name": ch.Name}}
+	if ch.Load != "" {
+		meta.Attributes["load"] = ch.Load
+	}
+	if ch.NormallyClosed {
+		meta.Attributes["contact"] = "nc"
+	}
+	if ch.Inverted {
+		meta.Attributes["inverted"] = "true"
+	}
+	return meta
+}
+
+// inverted reports whether the load runs while the relay is released. An
+// inverted load on the normally closed contact runs while the relay is on.
+func (ch ChannelConfig) inverted() bool {
+	return ch.NormallyClosed != ch.Inverted
+}
+
+// NamedChannel is a channel resolved by a Registry.
+type NamedChannel struct {
+	Client  *Client
+	Channel Channel
+	Config  ChannelConfig
+}
+
+// Registry holds a Client per board of a Config and resolves channel names.
+type Registry struct {
+	boards    map[string]*Client
+	channels  map[string]NamedChannel
+	sequences map[string]Sequence
+}
+
+// NewRegistry creates a Client for every board of the config. Boards on the
+// same port share one serial transporter, so their frames never interleave
+// on the line. The serial ports are opened by the first command.
+func NewRegistry(cfg *Config) (*Registry, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	r := &Registry{
+		boards:    make(map[string]*Client, len(cfg.Boards)),
+		channels:  make(map[string]NamedChannel),
+		sequences: make(map[string]Sequence, len(cfg.Sequences)),
+	}
+	ports := make(map[string]*ClientHandler)
+	for _, b := range cfg.Boards {
+		handler := NewDefaultHandler(b.Port, b.SlaveID)
+		handler.BaudRate = b.baudRate()
+		c := NewClient(handler, b.length())
+		if shared, ok := ports[b.Port]; ok {
+			c.transporter = shared
+		} else {
+			ports[b.Port] = handler
+		}
+		for _, ch := range b.Named {
+			if err := c.SetChannelMeta(ch.Channel, ch.meta()); err != nil {
+				return nil, err
+			}
+			if err := c.SetInverted(ch.Channel, ch.inverted()); err != nil {
+				return nil, err
+			}
+			r.channels[ch.Name] = NamedChannel{Client: c, Channel: ch.Channel, Config: ch}
+		}
+		r.boards[b.Name] = c
+	}
+	for _, seq := range cfg.Sequences {
+		r.sequences[seq.Name] = seq
+	}
+	return r, nil
+}
+
+// Resolve returns the client and channel of a named channel.
+func (r *Registry) Resolve(name string) (*Client, Channel, error) {
+	ch, ok := r.channels[name]
+	if !ok {
+		return nil, 0, ErrUnknownChannel
+	}
+	return ch.Client, ch.Channel, nil
+}
+
+// Lookup returns the named channel with its configuration.
+func (r *Registry) Lookup(name string) (NamedChannel, bool) {
+	ch, ok := r.channels[name]
+	return ch, ok
+}
+
+// Board returns the client of the board, nil if there is none.
+func (r *Registry) Board(name string) *Client {
+	return r.boards[name]
+}
+
+// Sequence returns the sequence of the config.
+func (r *Registry) Sequence(name string) (Sequence, bool) {
+	seq, ok := r.sequences[name]
+	return seq, ok
+}
+
+// RunSequence runs the sequence of the config on its board, see
+// Client.RunSequence.
+func (r *Registry) RunSequence(ctx context.Context, name string, progress func(SequenceProgress)) error {
+	seq, ok := r.sequences[name]
+	if !ok {
+		return ErrUnknownSequence
+	}
+	return r.boards[seq.Board].RunSequence(ctx, seq, progress)
+}
+
+// Names returns the sorted names of all channels.
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.channels))
+	for name := range r.channels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Close closes the clients of all boards and returns the first error.
+func (r *Registry) Close() error {
+	var first error
+	for _, c := range r.boards {
+		if err := c.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
@@ -0,0 +1,122 @@
+package relay
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const testConfig = `{
+  "boards": [{
+    "name": "pumps", "port": "/dev/ttyUSB0", "slave_id": 2, "channels": 16,
+    "named": [
+      {"name": "pump.main", "channel": 7, "label": "主泵", "load": "motor"},
+      {"name": "valve.drain", "channel": 12, "normally_closed": true, "inverted": true},
+      {"name": "fan.vent", "channel": 3, "normally_closed": true}
+    ]
+  }, {
+    "name": "lights", "port": "/dev/ttyUSB1", "slave_id": 1, "baud_rate": 19200,
+    "named": [{"name": "lamp.porch", "channel": 1, "load": "lamp"}]
+  }]
+}`
+
+func TestRegistry_Resolve(t *testing.T) {
+	cfg, err := ReadConfig(strings.NewReader(testConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewRegistry(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pumps, lights := r.Board("pumps"), r.Board("lights")
+	if pumps.Length() != 16 || lights.Length() != DefaultBranchesLength {
+		t.Fatalf("lengths %d %d", pumps.Length(), lights.Length())
+	}
+	if h := pumps.packager.(*ClientHandler); h.SlaveId != 2 || h.BaudRate != 9600 {
+		t.Fatalf("pumps handler %+v", h.relaySerialTransporter.Config)
+	}
+	if h := lights.packager.(*ClientHandler); h.BaudRate != 19200 {
+		t.Fatalf("lights baud rate %d", h.BaudRate)
+	}
+
+	c, ch, err := r.Resolve("pump.main")
+	if err != nil || c != pumps || ch != 7 {
+		t.Fatalf("pump.main resolved to %p %d %v", c, ch, err)
+	}
+	if meta := pumps.ChannelMeta(7); meta.Label != "主泵" || meta.Attributes["load"] != "motor" {
+		t.Fatalf("meta %+v", meta)
+	}
+	if drain, ok := r.Lookup("valve.drain"); !ok || !drain.Config.NormallyClosed || !drain.Config.Inverted {
+		t.Fatalf("valve.drain %+v", drain)
+	}
+	// normally closed and inverted cancel out
+	if !pumps.Inverted(3) || pumps.Inverted(7) || pumps.Inverted(12) {
+		t.Fatal("polarity not taken from the config")
+	}
+	if _, _, err := r.Resolve("pump.spare"); err != ErrUnknownChannel {
+		t.Fatalf("err %v, want %v", err, ErrUnknownChannel)
+	}
+	if names := r.Names(); strings.Join(names, ",") != "fan.vent,lamp.porch,pump.main,valve.drain" {
+		t.Fatalf("names %v", names)
+	}
+
+	board := &fakeBoard{}
+	pumps.transporter = board
+	if err := c.On(ch); err != nil {
+		t.Fatal(err)
+	}
+	if board.get() != 1<<6 {
+		t.Fatalf("board %#x", board.get())
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	for _, s := range []string{
+		`{"boards": [{"name": "a", "port": "p"}, {"name": "a", "port": "q"}]}`,
+		`{"boards": [{"name": "a"}]}`,
+		`{"boards": [{"name": "a", "port": "p", "channels": 33}]}`,
+		`{"boards": [{"name": "a", "port": "p", "named": [{"name": "x", "channel": 1}, {"name": "x", "channel": 2}]}]}`,
+		`{"boards": [{"name": "a", "port": "p", "typo": 1}]}`,
+	} {
+		if _, err := ReadConfig(strings.NewReader(s)); err == nil {
+			t.Fatalf("config %s accepted", s)
+		}
+	}
+	_, err := ReadConfig(strings.NewReader(`{"boards": [{"name": "a", "port": "p", "named": [{"name": "x", "channel": 9}]}]}`))
+	if !errors.Is(err, ErrBranchesLength) {
+		t.Fatalf("err %v, want %v", err, ErrBranchesLength)
+	}
+}
+
+func TestRegistry_SharedPort(t *testing.T) {
+	cfg, err := ReadConfig(strings.NewReader(`{"boards": [
+  {"name": "east", "port": "/dev/ttyUSB0", "slave_id": 1},
+  {"name": "west", "port": "/dev/ttyUSB0", "slave_id": 2},
+  {"name": "yard", "port": "/dev/ttyUSB1", "slave_id": 1}
+]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewRegistry(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	east, west, yard := r.Board("east"), r.Board("west"), r.Board("yard")
+	if east.transporter != west.transporter || east.transporter == yard.transporter {
+		t.Fatal("boards on one port do not share the transporter")
+	}
+	adu, err := west.packager.Encode(&ProtocolDataUnit{FunctionCode: RequestReadStatus, Data: []byte{0, 0, 0, 0}})
+	if err != nil || adu[1] != 2 {
+		t.Fatalf("west frame % x err %v, want slave id 2", adu, err)
+	}
+
+	for _, s := range []string{
+		`{"boards": [{"name": "a", "port": "p", "slave_id": 1}, {"name": "b", "port": "p", "slave_id": 1}]}`,
+		`{"boards": [{"name": "a", "port": "p", "slave_id": 1}, {"name": "b", "port": "p", "slave_id": 2, "baud_rate": 19200}]}`,
+	} {
+		if _, err := ReadConfig(strings.NewReader(s)); err == nil {
+			t.Fatalf("config %s accepted", s)
+		}
+	}
+}
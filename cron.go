@@ -0,0 +1,238 @@
+package relay
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrCronSpec = errors.New("cron 表达式无效")
+
+// Trigger computes the times a scheduled job runs. Cron, Once and Sun
+// implement it.
+type Trigger interface {
+	// Next returns the first run strictly after the time, zero if there
+	// is none.
+	Next(after time.Time) time.Time
+	String() string
+}
+
+// Cron is a Trigger for a standard five field cron expression: minute,
+// hour, day of month, month and day of week. Fields accept *, lists, ranges
+// and steps such as "*/15" or "1-5/2"; months and days of week may be given
+// as JAN-DEC and SUN-SAT, Sunday is 0 or 7. If both day fields are
+// restricted a day matching either runs, as in crontab. The descriptors
+// @yearly, @monthly, @weekly, @daily and @hourly are supported too.
+//
+// Times are matched in the location of the Cron. Runs in a skipped hour
+// of a daylight saving change do not happen that day, runs in a repeated
+// hour happen once.
+type Cron struct {
+	spec                     string
+	loc                      *time.Location
+	minute, hour, dom, month uint64
+	dow                      uint64
+	domAny, dowAny           bool
+}
+
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var (
+	cronMonths = []string{"JAN", "FEB", "MAR", "APR", "MAY", "JUN", "JUL", "AUG", "SEP", "OCT", "NOV", "DEC"}
+	cronDays   = []string{"SUN", "MON", "TUE", "WED", "THU", "FRI", "SAT"}
+)
+
+// ParseCron parses a cron expression matched in loc, time.Local if nil.
+func ParseCron(spec string, loc *time.Location) (*Cron, error) {
+	if loc == nil {
+		loc = time.Local
+	}
+	expr := spec
+	if d, ok := cronDescriptors[strings.ToLower(strings.TrimSpace(spec))]; ok {
+		expr = d
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("%w: %q", ErrCronSpec, spec)
+	}
+	c := &Cron{spec: spec, loc: loc}
+	var err error
+	if c.minute, err = cronField(fields[0], 0, 59, nil); err != nil {
+		return nil, err
+	}
+	if c.hour, err = cronField(fields[1], 0, 23, nil); err != nil {
+		return nil, err
+	}
+	if c.dom, err = cronField(fields[2], 1, 31, nil); err != nil {
+		return nil, err
+	}
+	if c.month, err = cronField(fields[3], 1, 12, cronMonths); err != nil {
+		return nil, err
+	}
+	if c.dow, err = cronField(fields[4], 0, 7, cronDays); err != nil {
+		return nil, err
+	}
+	// 7 is Sunday too
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	c.domAny = fields[2] == "*" || fields[2] == "?"
+	c.dowAny = fields[4] == "*" || fields[4] == "?"
+	return c, nil
+}
+
+// cronField parses a field into a bit set of the allowed values. names are
+// the names of the values starting at min.
+func cronField(field string, min, max int, names []string) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.IndexByte(part, '/'); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("%w: step %q", ErrCronSpec, part)
+			}
+			step = n
+			part = part[:i]
+		}
+		lo, hi := min, max
+		switch {
+		case part == "*" || part == "?":
+		case strings.IndexByte(part, '-') > 0:
+			i := strings.IndexByte(part, '-')
+			var err error
+			if lo, err = cronValue(part[:i], min, max, names); err != nil {
+				return 0, err
+			}
+			if hi, err = cronValue(part[i+1:], min, max, names); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("%w: range %q", ErrCronSpec, part)
+			}
+		default:
+			v, err := cronValue(part, min, max, names)
+			if err != nil {
+				return 0, err
+			}
+			lo = v
+			if step == 1 {
+				hi = v
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func cronValue(s string, min, max int, names []string) (int, error) {
+	for i, name := range names {
+		if strings.EqualFold(s, name) {
+			return min + i, nil
+		}
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < min || v > max {
+		return 0, fmt.Errorf("%w: value %q", ErrCronSpec, s)
+	}
+	return v, nil
+}
+
+// Next returns the first matching minute after the time, zero if none
+// matches within five years, e.g. for "0 0 30 2 *".
+func (c *Cron) Next(after time.Time) time.Time {
+	t := after.In(c.loc)
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, c.loc)
+	t = c.advance(t, t.Add(time.Minute))
+	limit := t.Year() + 5
+	for t.Year() <= limit {
+		if c.month&(1<<uint(t.Month())) == 0 {
+			t = c.advance(t, time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, c.loc))
+			continue
+		}
+		if !c.day(t) {
+			t = c.advance(t, time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, c.loc))
+			continue
+		}
+		if c.hour&(1<<uint(t.Hour())) == 0 {
+			t = c.advance(t, time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, c.loc))
+			continue
+		}
+		if c.minute&(1<<uint(t.Minute())) == 0 || c.repeated(t) {
+			t = c.advance(t, t.Add(time.Minute))
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// advance returns next, or a minute after t if daylight saving time moved
+// next back to or before t.
+func (c *Cron) advance(t, next time.Time) time.Time {
+	if next.After(t) {
+		return next
+	}
+	return t.Add(time.Minute)
+}
+
+// repeated reports whether the wall clock time of t already happened
+// before a daylight saving change that set the clocks back.
+func (c *Cron) repeated(t time.Time) bool {
+	_, off := t.Zone()
+	_, before := t.Add(-3 * time.Hour).Zone()
+	if before <= off {
+		return false
+	}
+	earlier := t.Add(-time.Duration(before-off) * time.Second)
+	return earlier.Day() == t.Day() && earlier.Hour() == t.Hour() && earlier.Minute() == t.Minute()
+}
+
+func (c *Cron) day(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	switch {
+	case c.domAny && c.dowAny:
+		return true
+	case c.domAny:
+		return dow
+	case c.dowAny:
+		return dom
+	}
+	return dom || dow
+}
+
+// Location returns the location the expression is matched in.
+func (c *Cron) Location() *time.Location {
+	return c.loc
+}
+
+func (c *Cron) String() string {
+	return fmt.Sprintf("cron %q %s", c.spec, c.loc)
+}
+
+// Once is a Trigger running once at the time.
+type Once time.Time
+
+func (o Once) Next(after time.Time) time.Time {
+	if t := time.Time(o); t.After(after) {
+		return t
+	}
+	return time.Time{}
+}
+
+func (o Once) String() string {
+	return fmt.Sprintf("once %s", time.Time(o).Format(time.RFC3339))
+}
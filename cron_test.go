@@ -0,0 +1,74 @@
+package relay
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestCron_Next(t *testing.T) {
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Fatal(err)
+	}
+	from := time.Date(2021, 6, 1, 10, 30, 15, 0, shanghai) // Tuesday
+	for _, tt := range []struct {
+		spec string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2021, 6, 1, 10, 31, 0, 0, shanghai)},
+		{"*/15 * * * *", time.Date(2021, 6, 1, 10, 45, 0, 0, shanghai)},
+		{"0 6,18 * * *", time.Date(2021, 6, 1, 18, 0, 0, 0, shanghai)},
+		{"30 10 * * *", time.Date(2021, 6, 2, 10, 30, 0, 0, shanghai)},
+		{"0 8 * * MON-FRI", time.Date(2021, 6, 2, 8, 0, 0, 0, shanghai)},
+		{"0 8 * * sat,7", time.Date(2021, 6, 5, 8, 0, 0, 0, shanghai)},
+		{"0 0 1 */3 *", time.Date(2021, 7, 1, 0, 0, 0, 0, shanghai)},
+		{"0 0 13 * 5", time.Date(2021, 6, 4, 0, 0, 0, 0, shanghai)},
+		{"0 0 29 2 *", time.Date(2024, 2, 29, 0, 0, 0, 0, shanghai)},
+		{"5/20 9-11 * JUN *", time.Date(2021, 6, 1, 10, 45, 0, 0, shanghai)},
+		{"@monthly", time.Date(2021, 7, 1, 0, 0, 0, 0, shanghai)},
+		{"@hourly", time.Date(2021, 6, 1, 11, 0, 0, 0, shanghai)},
+		{"0 0 30 2 *", time.Time{}},
+	} {
+		c, err := ParseCron(tt.spec, shanghai)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.spec, err)
+		}
+		if got := c.Next(from); !got.Equal(tt.want) {
+			t.Errorf("%s: next %v, want %v", tt.spec, got, tt.want)
+		}
+	}
+
+	// the same instant matched in another zone
+	c, _ := ParseCron("0 8 * * *", time.UTC)
+	if got := c.Next(from); !got.Equal(time.Date(2021, 6, 1, 8, 0, 0, 0, time.UTC)) {
+		t.Errorf("utc next %v", got)
+	}
+}
+
+func TestCron_DaylightSaving(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, _ := ParseCron("30 2 * * *", ny)
+	// 2:30 does not exist on 14 March 2021
+	if got := c.Next(time.Date(2021, 3, 13, 12, 0, 0, 0, ny)); !got.Equal(time.Date(2021, 3, 15, 2, 30, 0, 0, ny)) {
+		t.Errorf("next %v, want 15 March", got)
+	}
+	// 1:30 happens twice on 7 November 2021 and runs once
+	c, _ = ParseCron("30 1 * * *", ny)
+	first := c.Next(time.Date(2021, 11, 7, 0, 0, 0, 0, ny))
+	if second := c.Next(first); second.Sub(first) != 25*time.Hour {
+		t.Errorf("runs %v and %v", first, second)
+	}
+}
+
+func TestParseCron(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *",
+		"* * * 13 *", "* * * * 8", "*/0 * * * *", "5-1 * * * *", "x * * * *", "* * * FOO *"} {
+		if _, err := ParseCron(spec, time.UTC); err == nil {
+			t.Errorf("%q accepted", spec)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package relay
+
+import "time"
+
+// The methods below predate Channel. OffOne, OnOne, FlipOne and StatusOne
+// count from 1 like Channel does, all other ones count from 0.
+
+// OffOne 断开某路,从1开始
+//
+// Deprecated: use Off.
+func (c *Client) OffOne(i byte) error {
+	return c.Off(Channel(i))
+}
+
+// OnOne 闭合某路,从1开始
+//
+// Deprecated: use On.
+func (c *Client) OnOne(i byte) error {
+	return c.On(Channel(i))
+}
+
+// FlipOne 翻转某路,从1开始
+//
+// Deprecated: use Flip.
+func (c *Client) FlipOne(i byte) error {
+	return c.Flip(Channel(i))
+}
+
+// StatusOne 某路继电器状态,从1开始
+//
+// Deprecated: use StatusOf.
+func (c *Client) StatusOne(i byte) (byte, error) {
+	return c.StatusOf(Channel(i))
+}
+
+// OffGroup 断开组,从0开始
+//
+// Deprecated: use OffChannels.
+func (c *Client) OffGroup(i ...byte) error {
+	return c.OffChannels(channels(i)...)
+}
+
+// OnGroup 闭合组,从0开始
+//
+// Deprecated: use OnChannels.
+func (c *Client) OnGroup(i ...byte) error {
+	return c.OnChannels(channels(i)...)
+}
+
+// FlipGroup 组翻转,从0开始
+//
+// Deprecated: use FlipChannels.
+func (c *Client) FlipGroup(i ...byte) error {
+	return c.FlipChannels(channels(i)...)
+}
+
+// OffPoint 点动断开某路,从0开始,时间毫秒
+//
+// Deprecated: use PulseOff.
+func (c *Client) OffPoint(i byte, t int) error {
+	_, err := c.PulseOff(Channel(i+1), time.Duration(t)*time.Millisecond)
+	return err
+}
+
+// OnPoint 点动闭合某路,从0开始,时间毫秒
+//
+// Deprecated: use Pulse.
+func (c *Client) OnPoint(i byte, t int) error {
+	_, err := c.Pulse(Channel(i+1), time.Duration(t)*time.Millisecond)
+	return err
+}
+
+// FlipOneNil 翻转某路,从0开始
+//
+// Deprecated: use FlipNil.
+func (c *Client) FlipOneNil(i byte) error {
+	return c.FlipNil(Channel(i + 1))
+}
+
+// OffOneNil 断开某路,从0开始
+//
+// Deprecated: use OffNil.
+func (c *Client) OffOneNil(i byte) error {
+	return c.OffNil(Channel(i + 1))
+}
+
+// OnOneNil 吸合某路,从0开始。以前的版本多加了一路,现在与 OffOneNil 一致
+//
+// Deprecated: use OnNil.
+func (c *Client) OnOneNil(i byte) error {
+	return c.OnNil(Channel(i + 1))
+}
+
+// OffGroupNil 断开组,从0开始
+//
+// Deprecated: use OffChannelsNil.
+func (c *Client) OffGroupNil(i ...byte) error {
+	return c.OffChannelsNil(channels(i)...)
+}
+
+// OnGroupNil 吸合组,从0开始
+//
+// Deprecated: use OnChannelsNil.
+func (c *Client) OnGroupNil(i ...byte) error {
+	return c.OnChannelsNil(channels(i)...)
+}
+
+// FlipGroupNil 翻转组,从0开始
+//
+// Deprecated: use FlipChannelsNil.
+func (c *Client) FlipGroupNil(i ...byte) error {
+	return c.FlipChannelsNil(channels(i)...)
+}
+
+// OnPointNil 点动闭合,从0开始,时间毫秒
+//
+// Deprecated: use PulseNil.
+func (c *Client) OnPointNil(i byte, t int) error {
+	return c.PulseNil(Channel(i+1), time.Duration(t)*time.Millisecond)
+}
+
+// OffPointNil 点动断开,从0开始,时间毫秒
+//
+// Deprecated: use PulseOffNil.
+func (c *Client) OffPointNil(i byte, t int) error {
+	return c.PulseOffNil(Channel(i+1), time.Duration(t)*time.Millisecond)
+}
+
+// channels converts channel numbers counting from 0.
+func channels(i []byte) []Channel {
+	chs := make([]Channel, len(i))
+	for k, v := range i {
+		chs[k] = Channel(v + 1)
+	}
+	return chs
+}
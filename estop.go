@@ -0,0 +1,123 @@
+package relay
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// estopRetries is how often EmergencyStop sends OffAll until the board
+// reads back all channels off.
+const estopRetries = 3
+
+var (
+	ErrEmergencyStop = errors.New("急停已锁定,禁止吸合继电器")
+	ErrResetReason   = errors.New("解除急停需要说明原因")
+)
+
+// LatchRecord is an entry of the emergency stop audit log.
+type LatchRecord struct {
+	Time time.Time `json:"time"`
+	// Latched is true for EmergencyStop and false for Reset.
+	Latched bool   `json:"latched"`
+	Reason  string `json:"reason"`
+	// Err is the error of the emergency stop, empty if the board
+	// confirmed that all channels are off.
+	Err string `json:"err,omitempty"`
+}
+
+// EmergencyStop latches the client and switches all channels off. The latch
+// is set before waiting for the client lock, so commands queued behind the
+// current frame are refused as well. OffAll is sent up to estopRetries times
+// until RequestReadStatus confirms that all channels are off; the client stays
+// latched even if that fails.
+//
+// While latched every command that would switch a channel on fails with
+// ErrEmergencyStop, until Reset is called.
+func (c *Client) EmergencyStop() error {
+	atomic.StoreInt32(&c.latched, 1)
+	c.Lock()
+	defer c.unlock()
+	c.cancelPulses(c.full())
+	var err error
+	for i := 0; i < estopRetries; i++ {
+		if err = c.offAll(); err == nil {
+			break
+		}
+	}
+	record := LatchRecord{Time: c.clock.Now(), Latched: true, Reason: "emergency stop"}
+	if err != nil {
+		record.Err = err.Error()
+	}
+	c.audit = append(c.audit, record)
+	c.emit(EventEmergencyStop, 0, err)
+	return err
+}
+
+// offAll sends OffAll and reads the state back. Caller must hold the lock.
+func (c *Client) offAll() error {
+	if c.closed {
+		return ErrClientClosed
+	}
+	if c.packager == nil || c.transporter == nil {
+		return ErrPackagerNil
+	}
+	adu, err := c.packager.Encode(&ProtocolDataUnit{
+		FunctionCode: RequestRunCMDNil,
+		Data:         maskData(0),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = c.transporter.Send(adu)
+	c.track(err)
+	if err != nil {
+		return err
+	}
+	status, err := c.update(c.roundTrip(RequestReadStatus, maskData(0)))
+	if err != nil {
+		return err
+	}
+	if status&c.full() != 0 {
+		return ErrReturnResult
+	}
+	return nil
+}
+
+// Reset releases the emergency stop latch. The reason is kept in the audit
+// log. Channels stay off until they are switched on again.
+func (c *Client) Reset(reason string) error {
+	if reason == "" {
+		return ErrResetReason
+	}
+	c.Lock()
+	defer c.unlock()
+	if atomic.LoadInt32(&c.latched) == 0 {
+		return nil
+	}
+	atomic.StoreInt32(&c.latched, 0)
+	c.audit = append(c.audit, LatchRecord{Time: c.clock.Now(), Reason: reason})
+	c.emit(EventReset, 0, nil)
+	return nil
+}
+
+// Latched reports whether the client is latched by EmergencyStop.
+func (c *Client) Latched() bool {
+	return atomic.LoadInt32(&c.latched) == 1
+}
+
+// LatchLog returns the emergency stop audit log, oldest first.
+func (c *Client) LatchLog() []LatchRecord {
+	c.Lock()
+	defer c.Unlock()
+	return append([]LatchRecord(nil), c.audit...)
+}
+
+// checkLatch refuses to switch channels on while latched. Caller must hold
+// the lock.
+func (c *Client) checkLatch(from, to uint32) error {
+	if to&^from != 0 && c.Latched() {
+		return ErrEmergencyStop
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package relay
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestClient_EmergencyStop(t *testing.T) {
+	c, board := newTestClient(t, DefaultBranchesLength)
+	store := NewFileStore(filepath.Join(t.TempDir(), "relay.json"))
+	c.SetStateStore(store)
+	if err := c.OnChannels(1, 2, 3); err != nil {
+		t.Fatal(err)
+	}
+	p, err := c.Pulse(4, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	board.drop = map[byte]bool{RequestRunCMDNil: true}
+	if err := c.EmergencyStop(); err != ErrReturnResult {
+		t.Fatalf("err %v, want %v", err, ErrReturnResult)
+	}
+	if !c.Latched() || p.Err() != ErrPulseCanceled {
+		t.Fatal("client not latched after a failed emergency stop")
+	}
+	board.drop = nil
+	if err := c.EmergencyStop(); err != nil {
+		t.Fatal(err)
+	}
+	if board.get() != 0 {
+		t.Fatalf("board %#x, want all off", board.get())
+	}
+
+	refused := []func() error{
+		func() error { return c.On(1) },
+		func() error { return c.OnNil(1) },
+		func() error { return c.FlipChannels(2) },
+		c.OnAll,
+		func() error { _, err := c.Pulse(1, time.Second); return err },
+		func() error { _, err := c.PulseOff(1, time.Second); return err },
+	}
+	for k, do := range refused {
+		if err := do(); err != ErrEmergencyStop {
+			t.Fatalf("command %d: err %v, want %v", k, err, ErrEmergencyStop)
+		}
+	}
+	if err := c.Off(1); err != nil {
+		t.Fatalf("switching off refused while latched: %v", err)
+	}
+
+	// the latch survives a restart
+	restarted, _ := newTestClient(t, DefaultBranchesLength)
+	restarted.SetStateStore(store)
+	if err := restarted.Restore(RestoreCommanded); err != nil {
+		t.Fatal(err)
+	}
+	if !restarted.Latched() {
+		t.Fatal("latch not restored")
+	}
+
+	if err := c.Reset(""); err != ErrResetReason {
+		t.Fatalf("err %v, want %v", err, ErrResetReason)
+	}
+	if err := c.Reset("fault cleared by operator"); err != nil {
+		t.Fatal(err)
+	}
+	if c.Latched() {
+		t.Fatal("client still latched after reset")
+	}
+	if err := c.On(1); err != nil {
+		t.Fatal(err)
+	}
+	log := c.LatchLog()
+	if len(log) != 3 || !log[0].Latched || log[0].Err == "" || log[2].Latched ||
+		log[2].Reason != "fault cleared by operator" {
+		t.Fatalf("audit log %+v", log)
+	}
+}
@@ -0,0 +1,128 @@
+package relay
+
+import "time"
+
+// EventKind identifies what an Event reports.
+type EventKind int
+
+const (
+	// EventPulseEnded is emitted when a pulse ran for its full duration.
+	EventPulseEnded EventKind = iota + 1
+	// EventPulseCanceled is emitted when a pulse was replaced by a
+	// conflicting command on the same channel or could not be chained.
+	EventPulseCanceled
+	// EventStateSaveFailed is emitted when the StateStore failed to save.
+	EventStateSaveFailed
+	// EventSafeStateApplied is emitted when the safe state was sent.
+	EventSafeStateApplied
+	// EventConnectionLost is emitted when a frame could not be sent.
+	EventConnectionLost
+	// EventConnectionRestored is emitted on the first frame sent after
+	// EventConnectionLost.
+	EventConnectionRestored
+	// EventWatchdogTripped is emitted when a watchdog was not kicked in time.
+	EventWatchdogTripped
+	// EventWatchdogSafe is emitted when the board confirmed that the
+	// channels of a tripped watchdog are off.
+	EventWatchdogSafe
+	// EventMaxOnTime is emitted when a channel was switched off because it
+	// reached its maximum on-time. Err is set if switching off failed, it is
+	// retried every second until it succeeds.
+	EventMaxOnTime
+	// EventWearWarning is emitted when a channel reaches the warning
+	// threshold and again when it reaches the rated cycles of its WearLimit.
+	EventWearWarning
+	// EventEmergencyStop is emitted by EmergencyStop, Err is set if the
+	// board did not confirm that all channels are off.
+	EventEmergencyStop
+	// EventReset is emitted when the emergency stop latch is released.
+	EventReset
+	// EventLockout is emitted when a channel is locked out, Err is set if
+	// switching it off failed.
+	EventLockout
+	// EventLockoutCleared is emitted when a lockout is removed.
+	EventLockoutCleared
+	// EventHoldExpired is emitted when a Hold was not renewed in time and
+	// was released.
+	EventHoldExpired
+	// EventLeaseExpired is emitted when a host side Lease was not renewed
+	// in time and its channel was switched off. Leases kept by the board
+	// report EventPulseEnded instead.
+	EventLeaseExpired
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case EventPulseEnded:
+		return "pulse ended"
+	case EventPulseCanceled:
+		return "pulse canceled"
+	case EventStateSaveFailed:
+		return "state save failed"
+	case EventSafeStateApplied:
+		return "safe state applied"
+	case EventConnectionLost:
+		return "connection lost"
+	case EventConnectionRestored:
+		return "connection restored"
+	case EventWatchdogTripped:
+		return "watchdog tripped"
+	case EventWatchdogSafe:
+		return "watchdog safe"
+	case EventMaxOnTime:
+		return "max on-time reached"
+	case EventWearWarning:
+		return "wear warning"
+	case EventEmergencyStop:
+		return "emergency stop"
+	case EventReset:
+		return "reset"
+	case EventLockout:
+		return "lockout"
+	case EventLockoutCleared:
+		return "lockout cleared"
+	case EventHoldExpired:
+		return "hold expired"
+	case EventLeaseExpired:
+		return "lease expired"
+	}
+	return "unknown"
+}
+
+// Event is reported to the handler set with Client.SetEventHandler.
+type Event struct {
+	Kind EventKind
+	// Channel is the channel the event belongs to, zero for events about
+	// the whole client.
+	Channel Channel
+	Time    time.Time
+	// Err is the cause of the event, if any.
+	Err error
+}
+
+// SetEventHandler sets the function called for every event of the client.
+// The handler is called without the client lock held, so it may use the
+// client, but it should return quickly as it delays the command that
+// caused the event.
+func (c *Client) SetEventHandler(handler func(Event)) {
+	c.Lock()
+	defer c.Unlock()
+	c.handler = handler
+}
+
+// notify reports an event from outside a command.
+func (c *Client) notify(kind EventKind, channel Channel, err error) {
+	c.Lock()
+	c.emit(kind, channel, err)
+	c.unlock()
+}
+
+// emit queues an event to be reported by unlock. Caller must hold the lock.
+func (c *Client) emit(kind EventKind, channel Channel, err error) {
+	c.events = append(c.events, Event{
+		Kind:    kind,
+		Channel: channel,
+		Time:    c.clock.Now(),
+		Err:     err,
+	})
+}
@@ -108,12 +108,16 @@ type relaySerialTransporter struct {
 }
 
 func (mb *relaySerialTransporter) Send(aduRequest []byte) (aduResponse []byte, err error) {
+	// Boards on one line may share the transporter
+	mb.serialPort.mu.Lock()
+	defer mb.serialPort.mu.Unlock()
+
 	// Make sure port is connected
 	if err = mb.serialPort.connect(); err != nil {
 		return
 	}
 	// Start the timer to close when idle
-	mb.serialPort.lastActivity = time.Now()
+	mb.serialPort.lastActivity = mb.serialPort.clock().Now()
 	mb.serialPort.startCloseTimer()
 
 	// Send the request
@@ -127,7 +131,7 @@ func (mb *relaySerialTransporter) Send(aduRequest []byte) (aduResponse []byte, e
 	if bytesToRead == 0 {
 		return
 	}
-	time.Sleep(mb.calculateDelay(len(aduRequest) + bytesToRead))
+	mb.serialPort.clock().Sleep(mb.calculateDelay(len(aduRequest) + bytesToRead))
 
 	var n int
 	var n1 int
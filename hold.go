@@ -0,0 +1,135 @@
+package relay
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrHolder = errors.New("占用需要持有者名称")
+
+// Hold is a reference to a channel shared by several holders, see
+// Client.Acquire.
+type Hold struct {
+	Channel Channel
+	Holder  string
+
+	client *Client
+	// timer, deadline and released are guarded by the client lock.
+	timer    Timer
+	deadline time.Time
+	released bool
+}
+
+// SetHoldTimeout sets how long a Hold lives without Renew before it is
+// considered abandoned and released. Zero, the default, keeps holds until
+// they are released.
+func (c *Client) SetHoldTimeout(d time.Duration) {
+	c.Lock()
+	defer c.Unlock()
+	c.holdTimeout = d
+}
+
+// Acquire switches the channel on and keeps it on until every Hold of it
+// has been released. Commands like Off still act on the channel directly,
+// a later Acquire switches it on again.
+func (c *Client) Acquire(ch Channel, holder string) (*Hold, error) {
+	if err := c.check(ch); err != nil {
+		return nil, err
+	}
+	if holder == "" {
+		return nil, ErrHolder
+	}
+	c.acquire(RequestOnOne, channelData(ch))
+	defer c.unlock()
+	if c.stat[ch-1] == 0 {
+		status, err := c.commandLocked(RequestOnOne, channelData(ch))
+		if err != nil {
+			return nil, err
+		}
+		if status&ch.bit() == 0 {
+			return nil, ErrReturnResult
+		}
+	}
+	h := &Hold{Channel: ch, Holder: holder, client: c}
+	if c.holds == nil {
+		c.holds = make(map[Channel][]*Hold)
+	}
+	c.holds[ch] = append(c.holds[ch], h)
+	if c.holdTimeout > 0 {
+		h.deadline = c.clock.Now().Add(c.holdTimeout)
+		h.timer = c.clock.AfterFunc(c.holdTimeout, h.expire)
+	}
+	return h, nil
+}
+
+// Release drops the hold and switches the channel off if it was the last
+// one. Releasing a hold twice does nothing.
+func (c *Client) Release(h *Hold) error {
+	c.Lock()
+	defer c.unlock()
+	return c.release(h)
+}
+
+// Holders returns the holders of the channel.
+func (c *Client) Holders(ch Channel) []string {
+	c.Lock()
+	defer c.Unlock()
+	holders := make([]string, len(c.holds[ch]))
+	for k, h := range c.holds[ch] {
+		holders[k] = h.Holder
+	}
+	return holders
+}
+
+// Renew restarts the abandon timeout of the hold. It reports false if the
+// hold has already been released.
+func (h *Hold) Renew() bool {
+	c := h.client
+	c.Lock()
+	defer c.Unlock()
+	if h.released {
+		return false
+	}
+	if h.timer != nil {
+		h.deadline = c.clock.Now().Add(c.holdTimeout)
+		h.timer.Reset(c.holdTimeout)
+	}
+	return true
+}
+
+// release drops the hold. Caller must hold the lock.
+func (c *Client) release(h *Hold) error {
+	if h.released {
+		return nil
+	}
+	h.released = true
+	if h.timer != nil {
+		h.timer.Stop()
+	}
+	holds := c.holds[h.Channel]
+	for k, v := range holds {
+		if v == h {
+			holds = append(holds[:k], holds[k+1:]...)
+			break
+		}
+	}
+	if len(holds) > 0 {
+		c.holds[h.Channel] = holds
+		return nil
+	}
+	delete(c.holds, h.Channel)
+	_, err := c.commandOff(RequestOffOne, channelData(h.Channel))
+	return err
+}
+
+func (h *Hold) expire() {
+	c := h.client
+	c.Lock()
+	defer c.unlock()
+	// renewed after the timer fired
+	if h.released || c.closed || h.deadline.After(c.clock.Now()) {
+		return
+	}
+	err := c.release(h)
+	c.emit(EventHoldExpired, h.Channel, err)
+}
@@ -0,0 +1,105 @@
+package relay
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClient_Acquire(t *testing.T) {
+	c, board := newTestClient(t, DefaultBranchesLength)
+	clock := newFakeClock()
+	c.SetClock(clock)
+	c.SetHoldTimeout(time.Minute)
+	var expired []Channel
+	c.SetEventHandler(func(e Event) {
+		if e.Kind == EventHoldExpired {
+			expired = append(expired, e.Channel)
+		}
+	})
+
+	fan1, err := c.Acquire(4, "kitchen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fan2, err := c.Acquire(4, "bathroom")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Acquire(4, ""); err != ErrHolder {
+		t.Fatalf("err %v, want %v", err, ErrHolder)
+	}
+	if board.get() != 0x08 || len(c.Holders(4)) != 2 {
+		t.Fatalf("board %#x holders %v", board.get(), c.Holders(4))
+	}
+	if err := c.Release(fan1); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Release(fan1); err != nil {
+		t.Fatal(err)
+	}
+	if board.get() != 0x08 {
+		t.Fatal("channel switched off while still held")
+	}
+
+	clock.Advance(50 * time.Second)
+	if !fan2.Renew() {
+		t.Fatal("hold released before its timeout")
+	}
+	clock.Advance(50 * time.Second)
+	if board.get() != 0x08 {
+		t.Fatal("renewed hold expired")
+	}
+	clock.Advance(10 * time.Second)
+	if board.get() != 0 || fan2.Renew() || len(c.Holders(4)) != 0 {
+		t.Fatalf("abandoned hold not released, board %#x", board.get())
+	}
+	if len(expired) != 1 || expired[0] != 4 {
+		t.Fatalf("expired %v, want channel 4", expired)
+	}
+}
+
+func TestHold_RenewRacingExpiry(t *testing.T) {
+	c, board := newTestClient(t, DefaultBranchesLength)
+	clock := newFakeClock()
+	c.SetClock(clock)
+	c.SetHoldTimeout(time.Minute)
+	h, err := c.Acquire(4, "kitchen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(50 * time.Second)
+	if !h.Renew() {
+		t.Fatal("hold released before its timeout")
+	}
+	// a timer that fired just before the renewal
+	h.expire()
+	if board.get() != 0x08 || len(c.Holders(4)) != 1 {
+		t.Fatalf("renewed hold released, board %#x", board.get())
+	}
+}
+
+func TestClient_CloseStopsHoldTimers(t *testing.T) {
+	c, _ := newTestClient(t, DefaultBranchesLength)
+	clock := newFakeClock()
+	c.SetClock(clock)
+	c.SetHoldTimeout(time.Minute)
+	var events []Event
+	c.SetEventHandler(func(e Event) {
+		if e.Kind == EventHoldExpired || e.Kind == EventLeaseExpired {
+			events = append(events, e)
+		}
+	})
+	if _, err := c.Acquire(1, "fan"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.OnFor(2, 10*time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(11 * time.Hour)
+	if len(events) != 0 {
+		t.Fatalf("events %v after close", events)
+	}
+}
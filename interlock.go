@@ -0,0 +1,100 @@
+package relay
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrInterlockChannels = errors.New("互锁至少需要两路")
+
+// Interlock is a set of channels that may never be on together, for example
+// the two relays of a reversible motor.
+type Interlock struct {
+	Channels []Channel
+	// DeadTime is how long a channel of the set must have been off before
+	// another one may be switched on.
+	DeadTime time.Duration
+}
+
+// InterlockError is returned when a command would violate an interlock. It
+// is returned before any frame is sent.
+type InterlockError struct {
+	// Channel is the channel the command would switch on.
+	Channel Channel
+	// Conflict is the interlocked channel that is on or was switched off
+	// less than the dead time ago.
+	Conflict Channel
+	// Remaining is the dead time left, zero if Conflict is still on.
+	Remaining time.Duration
+}
+
+func (e *InterlockError) Error() string {
+	if e.Remaining > 0 {
+		return fmt.Sprintf("继电器互锁: 第%d路需等待第%d路断开后 %v", e.Channel, e.Conflict, e.Remaining)
+	}
+	return fmt.Sprintf("继电器互锁: 第%d路与第%d路不能同时吸合", e.Channel, e.Conflict)
+}
+
+// AddInterlock makes the channels mutually exclusive. Every command that
+// would switch on more than one of them, or switch one on within deadTime
+// after another was switched off, fails with an *InterlockError.
+func (c *Client) AddInterlock(deadTime time.Duration, chs ...Channel) error {
+	mask, err := c.mask(chs...)
+	if err != nil {
+		return err
+	}
+	if len(chs) < 2 || mask&(mask-1) == 0 {
+		return ErrInterlockChannels
+	}
+	c.Lock()
+	defer c.Unlock()
+	c.interlocks = append(c.interlocks, Interlock{
+		Channels: append([]Channel(nil), chs...),
+		DeadTime: deadTime,
+	})
+	return nil
+}
+
+// Interlocks returns the configured interlocks.
+func (c *Client) Interlocks() []Interlock {
+	c.Lock()
+	defer c.Unlock()
+	return append([]Interlock(nil), c.interlocks...)
+}
+
+// checkInterlocks checks the channels switched on between from and to.
+// Caller must hold the lock.
+func (c *Client) checkInterlocks(from, to uint32) error {
+	on := to &^ from
+	if on == 0 {
+		return nil
+	}
+	now := c.clock.Now()
+	for _, lock := range c.interlocks {
+		for _, ch := range lock.Channels {
+			if on&ch.bit() == 0 {
+				continue
+			}
+			for _, other := range lock.Channels {
+				if other == ch {
+					continue
+				}
+				if to&other.bit() != 0 {
+					return &InterlockError{Channel: ch, Conflict: other}
+				}
+				if lock.DeadTime <= 0 {
+					continue
+				}
+				// switched off by this command or within the dead time
+				if from&other.bit() != 0 {
+					return &InterlockError{Channel: ch, Conflict: other, Remaining: lock.DeadTime}
+				}
+				if d := lock.DeadTime - now.Sub(c.offAt[other-1]); d > 0 {
+					return &InterlockError{Channel: ch, Conflict: other, Remaining: d}
+				}
+			}
+		}
+	}
+	return nil
+}
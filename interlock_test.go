@@ -0,0 +1,102 @@
+package relay
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClient_Interlock(t *testing.T) {
+	c, board := newTestClient(t, DefaultBranchesLength)
+	clock := newFakeClock()
+	c.SetClock(clock)
+	if err := c.AddInterlock(0, 3, 3); err != ErrInterlockChannels {
+		t.Fatalf("err %v, want %v", err, ErrInterlockChannels)
+	}
+	if err := c.AddInterlock(time.Second, 1, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.AddInterlock(0, 5, 6); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.On(1); err != nil {
+		t.Fatal(err)
+	}
+	rejected := []struct {
+		name string
+		do   func() error
+	}{
+		{"On", func() error { return c.On(2) }},
+		{"OnNil", func() error { return c.OnNil(2) }},
+		{"OnChannels", func() error { return c.OnChannels(2, 3) }},
+		{"FlipChannels", func() error { return c.FlipChannels(1, 2) }},
+		{"OnAll", c.OnAll},
+		{"Pulse", func() error { _, err := c.Pulse(2, time.Second); return err }},
+	}
+	n := len(board.frames)
+	for _, r := range rejected {
+		err := r.do()
+		if e, ok := err.(*InterlockError); !ok || e.Channel != 2 && e.Channel != 5 {
+			t.Fatalf("%s: err %v, want *InterlockError", r.name, err)
+		}
+	}
+	if len(board.frames) != n {
+		t.Fatalf("frames %x sent for rejected commands", board.frames[n:])
+	}
+
+	if err := c.Off(1); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(400 * time.Millisecond)
+	err := c.On(2)
+	if e, ok := err.(*InterlockError); !ok || e.Conflict != 1 || e.Remaining != 600*time.Millisecond {
+		t.Fatalf("err %v, want dead time left", err)
+	}
+	clock.Advance(600 * time.Millisecond)
+	if err := c.On(2); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.FlipChannels(5, 6); err == nil {
+		t.Fatal("flip switched on both channels 5 and 6")
+	}
+	if err := c.On(5); err != nil {
+		t.Fatal(err)
+	}
+	if board.get() != 0x12 {
+		t.Fatalf("board %#x, want %#x", board.get(), 0x12)
+	}
+}
+
+func TestClient_InterlockPulseOff(t *testing.T) {
+	c, board := newTestClient(t, DefaultBranchesLength)
+	clock := newFakeClock()
+	c.SetClock(clock)
+	if err := c.AddInterlock(0, 1, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.On(1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.PulseOff(1, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	err := c.On(2)
+	if e, ok := err.(*InterlockError); !ok || e.Conflict != 1 || e.Remaining != 0 {
+		t.Fatalf("err %v, want channel 1 treated as on during its off pulse", err)
+	}
+	clock.Advance(time.Second)
+	if err := c.On(2); err == nil {
+		t.Fatal("channel 2 switched on after the off pulse of channel 1 ended")
+	}
+
+	// a command that cancels the pulse decides the channel itself
+	if _, err := c.PulseOff(1, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetAll(0x2); err != nil {
+		t.Fatal(err)
+	}
+	if got := board.get(); got != 2 {
+		t.Fatalf("board %#x, want %#x", got, 2)
+	}
+}
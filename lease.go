@@ -0,0 +1,158 @@
+package relay
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrLeaseExpired = errors.New("租约已到期")
+
+// Lease keeps a channel on for as long as it is renewed, see Client.OnFor.
+type Lease struct {
+	Channel Channel
+	TTL     time.Duration
+
+	client *Client
+	// pulse is the point command of a lease kept by the board.
+	pulse *Pulse
+
+	// end and timer of a host side lease are guarded by the client lock.
+	end   time.Time
+	timer Timer
+
+	once sync.Once
+	done chan struct{}
+}
+
+// OnFor switches the channel on for ttl. The lease must be renewed before
+// ttl has passed, otherwise the channel is switched off. Leases up to
+// MaxPointDuration are kept by the board with a point command, so the
+// channel is switched off even if the process dies. Longer leases fall back
+// to a host side timer.
+func (c *Client) OnFor(ch Channel, ttl time.Duration) (*Lease, error) {
+	if ttl <= MaxPointDuration {
+		p, err := c.pulse(RequestOnPoint, ch, ttl)
+		if err != nil {
+			return nil, err
+		}
+		return &Lease{Channel: ch, TTL: ttl, client: c, pulse: p, done: p.done}, nil
+	}
+	if err := c.check(ch); err != nil {
+		return nil, err
+	}
+	c.acquire(RequestOnOne, channelData(ch))
+	defer c.unlock()
+	status, err := c.commandLocked(RequestOnOne, channelData(ch))
+	if err != nil {
+		return nil, err
+	}
+	if status&ch.bit() == 0 {
+		return nil, ErrReturnResult
+	}
+	l := &Lease{Channel: ch, TTL: ttl, client: c, done: make(chan struct{})}
+	l.end = c.clock.Now().Add(ttl)
+	l.timer = c.clock.AfterFunc(ttl, l.expire)
+	if c.leases == nil {
+		c.leases = make(map[*Lease]bool)
+	}
+	c.leases[l] = true
+	return l, nil
+}
+
+// Renew extends the lease to TTL from now. It fails with ErrLeaseExpired
+// once the lease has ended.
+func (l *Lease) Renew() error {
+	c := l.client
+	c.Lock()
+	defer c.unlock()
+	if l.pulse != nil {
+		if err := l.pulse.restart(l.TTL); err != ErrPulseCanceled {
+			return err
+		}
+		return ErrLeaseExpired
+	}
+	select {
+	case <-l.done:
+		return ErrLeaseExpired
+	default:
+	}
+	l.end = c.clock.Now().Add(l.TTL)
+	l.timer.Reset(l.TTL)
+	return nil
+}
+
+// Release ends the lease and switches the channel off.
+func (l *Lease) Release() error {
+	c := l.client
+	c.Lock()
+	defer c.unlock()
+	if l.pulse != nil {
+		if c.pulses[l.Channel] != l.pulse {
+			return nil
+		}
+		_, err := c.commandOff(RequestOffOne, channelData(l.Channel))
+		return err
+	}
+	select {
+	case <-l.done:
+		return nil
+	default:
+	}
+	l.timer.Stop()
+	l.finish()
+	delete(c.leases, l)
+	_, err := c.commandOff(RequestOffOne, channelData(l.Channel))
+	return err
+}
+
+// Remaining returns the time left until the lease expires, zero once it
+// has ended.
+func (l *Lease) Remaining() time.Duration {
+	if l.pulse != nil {
+		return l.pulse.Remaining()
+	}
+	c := l.client
+	c.Lock()
+	defer c.Unlock()
+	select {
+	case <-l.done:
+		return 0
+	default:
+	}
+	if d := l.end.Sub(c.clock.Now()); d > 0 {
+		return d
+	}
+	return 0
+}
+
+// Done returns a channel that is closed when the lease has ended.
+func (l *Lease) Done() <-chan struct{} {
+	return l.done
+}
+
+func (l *Lease) finish() {
+	l.once.Do(func() {
+		close(l.done)
+	})
+}
+
+// expire switches the channel of a host side lease off.
+func (l *Lease) expire() {
+	c := l.client
+	c.Lock()
+	defer c.unlock()
+	select {
+	case <-l.done:
+		return
+	default:
+	}
+	// renewed after the timer fired
+	if c.closed || l.end.After(c.clock.Now()) {
+		return
+	}
+	l.finish()
+	delete(c.leases, l)
+	_, err := c.commandOff(RequestOffOne, channelData(l.Channel))
+	c.emit(EventLeaseExpired, l.Channel, err)
+}
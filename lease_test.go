@@ -0,0 +1,110 @@
+package relay
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClient_OnForPoint(t *testing.T) {
+	c, board := newTestClient(t, DefaultBranchesLength)
+	clock := newFakeClock()
+	c.SetClock(clock)
+
+	l, err := c.OnFor(2, 10*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if board.get() != 0x02 || board.frames[len(board.frames)-1] != RequestOnPoint {
+		t.Fatalf("board %#x frames %v", board.get(), board.frames)
+	}
+	clock.Advance(8 * time.Second)
+	if err := l.Renew(); err != nil {
+		t.Fatal(err)
+	}
+	if d := l.Remaining(); d != 10*time.Second {
+		t.Fatalf("remaining %v, want 10s", d)
+	}
+	clock.Advance(10 * time.Second)
+	select {
+	case <-l.Done():
+	default:
+		t.Fatal("lease did not expire")
+	}
+	if err := l.Renew(); err != ErrLeaseExpired {
+		t.Fatalf("err %v, want %v", err, ErrLeaseExpired)
+	}
+	if l.Remaining() != 0 {
+		t.Fatalf("remaining %v after expiry", l.Remaining())
+	}
+}
+
+func TestClient_OnForHost(t *testing.T) {
+	c, board := newTestClient(t, DefaultBranchesLength)
+	clock := newFakeClock()
+	c.SetClock(clock)
+	var expired []Channel
+	c.SetEventHandler(func(e Event) {
+		if e.Kind == EventLeaseExpired {
+			expired = append(expired, e.Channel)
+		}
+	})
+
+	l, err := c.OnFor(5, 10*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if board.get() != 0x10 || board.frames[len(board.frames)-1] != RequestOnOne {
+		t.Fatalf("board %#x frames %v", board.get(), board.frames)
+	}
+	clock.Advance(9 * time.Hour)
+	if err := l.Renew(); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(9*time.Hour + time.Minute)
+	if board.get() != 0x10 || l.Remaining() != time.Hour-time.Minute {
+		t.Fatalf("board %#x remaining %v", board.get(), l.Remaining())
+	}
+	clock.Advance(time.Hour)
+	if board.get() != 0 || l.Renew() != ErrLeaseExpired {
+		t.Fatalf("abandoned lease not switched off, board %#x", board.get())
+	}
+	if len(expired) != 1 || expired[0] != 5 {
+		t.Fatalf("expired %v, want channel 5", expired)
+	}
+
+	l, err = c.OnFor(6, 10*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Release(); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(11 * time.Hour)
+	if board.get() != 0 || len(expired) != 1 {
+		t.Fatalf("board %#x expired %v after release", board.get(), expired)
+	}
+}
+
+func TestLease_RenewConcurrent(t *testing.T) {
+	c, _ := newTestClient(t, DefaultBranchesLength)
+	l, err := c.OnFor(1, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			_ = l.Remaining()
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		if err := l.Renew(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	<-done
+	if err := l.Release(); err != nil {
+		t.Fatal(err)
+	}
+}
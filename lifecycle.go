@@ -0,0 +1,155 @@
+package relay
+
+import (
+	"errors"
+	"io"
+	"time"
+)
+
+var ErrClientClosed = errors.New("继电器客户端已关闭")
+
+// SafeMode selects the state the relays are put in when the client shuts
+// down or reconnects after an outage.
+type SafeMode int
+
+const (
+	// SafeLeave leaves the relays as they are.
+	SafeLeave SafeMode = iota
+	// SafeAllOff opens all relays.
+	SafeAllOff
+	// SafeMask sets the relays to SafePolicy.Mask.
+	SafeMask
+)
+
+// SafePolicy defines the safe state of the client.
+type SafePolicy struct {
+	Mode SafeMode
+	// Mask is the safe state for SafeMask, BIT0 is channel 1.
+	Mask uint32
+	// Outage, when positive, also applies the safe state on the first
+	// successful frame after the board was unreachable for at least Outage.
+	Outage time.Duration
+}
+
+// SetSafePolicy sets the safe state applied by Close and after outages.
+func (c *Client) SetSafePolicy(policy SafePolicy) {
+	c.Lock()
+	defer c.Unlock()
+	c.safe = policy
+}
+
+// Close applies the safe state, stops the pulse, hold and lease timers and
+// closes the transporter. Pulses left running by SafeLeave stay in the saved
+// state. The client can not be used afterwards.
+func (c *Client) Close() error {
+	c.Lock()
+	defer c.unlock()
+	if c.closed {
+		return nil
+	}
+	err := c.applySafe()
+	for _, p := range c.pulses {
+		p.timer.Stop()
+	}
+	for _, t := range c.limits {
+		if t != nil {
+			t.Stop()
+		}
+	}
+	for _, holds := range c.holds {
+		for _, h := range holds {
+			if h.timer != nil {
+				h.timer.Stop()
+			}
+		}
+	}
+	for l := range c.leases {
+		l.timer.Stop()
+	}
+	for w := range c.watchdogs {
+		w.halt()
+	}
+	c.closed = true
+	if closer, ok := c.transporter.(io.Closer); ok {
+		if e := closer.Close(); err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// applySafe sends the safe state. Locked-out channels are kept off and a
+// latched client opens all channels. If closing the channels of the mask
+// would violate an interlock, only the channels to open are opened and the
+// *InterlockError is returned and reported with EventSafeStateApplied.
+// Caller must hold the lock.
+func (c *Client) applySafe() error {
+	var mask uint32
+	switch c.safe.Mode {
+	case SafeAllOff:
+		mask = 0
+	case SafeMask:
+		mask = c.safe.Mask & c.full()
+	default:
+		return nil
+	}
+	// locked-out channels stay off, a latched client closes nothing
+	for ch := range c.lockouts {
+		mask &^= ch.bit()
+	}
+	if c.Latched() {
+		mask = 0
+	}
+	// a mask violating an interlock only opens channels
+	refused := c.checkInterlocks(c.cached(), mask)
+	if refused != nil {
+		mask &= c.cached()
+	}
+	c.cancelPulses(c.full())
+	status, err := c.update(c.roundTrip(RequestRunCMD, maskData(mask)))
+	if err == nil && status&c.full() != mask {
+		err = ErrReturnResult
+	}
+	if err != nil {
+		return err
+	}
+	c.emit(EventSafeStateApplied, 0, refused)
+	return refused
+}
+
+// reconnect applies the safe state before the first frame sent after a
+// prolonged outage. Caller must hold the lock.
+func (c *Client) reconnect() error {
+	if c.lost.IsZero() || c.safe.Outage <= 0 || c.safe.Mode == SafeLeave {
+		return nil
+	}
+	if c.clock.Now().Sub(c.lost) < c.safe.Outage {
+		return nil
+	}
+	err := c.applySafe()
+	// a refused interlock does not fail the command being sent
+	if _, ok := err.(*InterlockError); ok {
+		return nil
+	}
+	return err
+}
+
+// track records the outcome of a frame to detect outages. Caller must hold
+// the lock.
+func (c *Client) track(err error) {
+	if err != nil {
+		if c.lost.IsZero() {
+			c.lost = c.clock.Now()
+			c.emit(EventConnectionLost, 0, err)
+		}
+		// reopen the port on the next frame
+		if closer, ok := c.transporter.(io.Closer); ok {
+			_ = closer.Close()
+		}
+		return
+	}
+	if !c.lost.IsZero() {
+		c.lost = time.Time{}
+		c.emit(EventConnectionRestored, 0, nil)
+	}
+}
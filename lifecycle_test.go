@@ -0,0 +1,136 @@
+package relay
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestClient_Close(t *testing.T) {
+	c, board := newTestClient(t, DefaultBranchesLength)
+	c.SetSafePolicy(SafePolicy{Mode: SafeMask, Mask: 0x101})
+	if err := c.OnChannels(2, 3); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Pulse(4, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if board.get() != 0x01 {
+		t.Fatalf("board %#x, want safe state %#x", board.get(), 0x01)
+	}
+	if len(c.Pulses()) != 0 {
+		t.Fatalf("pulses %v still tracked", c.Pulses())
+	}
+	if err := c.On(2); err != ErrClientClosed {
+		t.Fatalf("err %v, want %v", err, ErrClientClosed)
+	}
+	if err := c.OffNil(2); err != ErrClientClosed {
+		t.Fatalf("err %v, want %v", err, ErrClientClosed)
+	}
+}
+
+func TestClient_SafeStateAfterOutage(t *testing.T) {
+	c, board := newTestClient(t, DefaultBranchesLength)
+	clock := newFakeClock()
+	c.SetClock(clock)
+	c.SetSafePolicy(SafePolicy{Mode: SafeAllOff, Outage: time.Minute})
+	var kinds []EventKind
+	c.SetEventHandler(func(e Event) {
+		kinds = append(kinds, e.Kind)
+	})
+	if err := c.OnChannels(1, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	lost := errors.New("unplugged")
+	board.fail(lost)
+	if err := c.On(3); err != lost {
+		t.Fatalf("err %v, want %v", err, lost)
+	}
+	clock.Advance(30 * time.Second)
+	board.fail(nil)
+	if err := c.On(3); err != nil {
+		t.Fatal(err)
+	}
+	if board.get() != 0x07 {
+		t.Fatalf("board %#x, safe state applied after a short outage", board.get())
+	}
+
+	board.fail(lost)
+	_ = c.On(4)
+	clock.Advance(time.Minute)
+	board.fail(nil)
+	if err := c.On(4); err != nil {
+		t.Fatal(err)
+	}
+	if board.get() != 0x08 {
+		t.Fatalf("board %#x, want safe state before channel 4", board.get())
+	}
+	want := []EventKind{
+		EventConnectionLost, EventConnectionRestored,
+		EventConnectionLost, EventConnectionRestored, EventSafeStateApplied,
+	}
+	if len(kinds) != len(want) {
+		t.Fatalf("events %v, want %v", kinds, want)
+	}
+	for k := range want {
+		if kinds[k] != want[k] {
+			t.Fatalf("events %v, want %v", kinds, want)
+		}
+	}
+}
+
+func TestClient_CloseKeepsLockout(t *testing.T) {
+	c, board := newTestClient(t, DefaultBranchesLength)
+	c.SetSafePolicy(SafePolicy{Mode: SafeMask, Mask: 0x03})
+	if err := c.Lockout(1, "alice", "pump service"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if board.get() != 0x02 {
+		t.Fatalf("board %#x, want locked-out channel 1 off", board.get())
+	}
+}
+
+func TestClient_SafeStateWhileLatched(t *testing.T) {
+	c, board := newTestClient(t, DefaultBranchesLength)
+	clock := newFakeClock()
+	c.SetClock(clock)
+	c.SetSafePolicy(SafePolicy{Mode: SafeMask, Mask: 0x03, Outage: time.Minute})
+	if err := c.EmergencyStop(); err != nil {
+		t.Fatal(err)
+	}
+	board.fail(errors.New("unplugged"))
+	_ = c.Off(1)
+	clock.Advance(2 * time.Minute)
+	board.fail(nil)
+	if err := c.Off(2); err != nil {
+		t.Fatal(err)
+	}
+	if board.get() != 0 || !c.Latched() {
+		t.Fatalf("board %#x latched %v, want all off", board.get(), c.Latched())
+	}
+}
+
+func TestClient_SafeStateInterlock(t *testing.T) {
+	c, board := newTestClient(t, DefaultBranchesLength)
+	if err := c.AddInterlock(0, 1, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.On(3); err != nil {
+		t.Fatal(err)
+	}
+	c.SetSafePolicy(SafePolicy{Mode: SafeMask, Mask: 0x03})
+	var e *InterlockError
+	if err := c.Close(); !errors.As(err, &e) {
+		t.Fatalf("err %v, want *InterlockError", err)
+	}
+	if board.get() != 0 {
+		t.Fatalf("board %#x, want interlocked channels left off", board.get())
+	}
+}
@@ -0,0 +1,90 @@
+package relay
+
+import "time"
+
+// maxOnRetry is the interval between attempts to switch off a channel that
+// exceeded its maximum on-time.
+const maxOnRetry = time.Second
+
+// SetMaxOn limits how long the channel may stay on. The on-time counts from
+// the command that switched the channel on, whatever its kind; when the limit
+// is reached the channel is switched off and EventMaxOnTime is emitted. A
+// limit of zero removes it. Limits are saved with the state.
+func (c *Client) SetMaxOn(ch Channel, d time.Duration) error {
+	if err := c.check(ch); err != nil {
+		return err
+	}
+	if d < 0 {
+		d = 0
+	}
+	c.Lock()
+	defer c.unlock()
+	c.maxOn[ch-1] = d
+	if c.stat[ch-1] == 1 && c.onAt[ch-1].IsZero() {
+		c.onAt[ch-1] = c.clock.Now()
+	}
+	c.armMaxOn(ch)
+	return nil
+}
+
+// MaxOn returns the maximum on-time of the channel, zero if unlimited.
+func (c *Client) MaxOn(ch Channel) time.Duration {
+	if c.check(ch) != nil {
+		return 0
+	}
+	c.Lock()
+	defer c.Unlock()
+	return c.maxOn[ch-1]
+}
+
+// OnSince returns when the channel was switched on, false if it is off.
+func (c *Client) OnSince(ch Channel) (time.Time, bool) {
+	if c.check(ch) != nil {
+		return time.Time{}, false
+	}
+	c.Lock()
+	defer c.Unlock()
+	if c.stat[ch-1] == 0 {
+		return time.Time{}, false
+	}
+	return c.onAt[ch-1], true
+}
+
+// armMaxOn starts or stops the on-time timer of the channel according to its
+// state. Caller must hold the lock.
+func (c *Client) armMaxOn(ch Channel) {
+	i := ch - 1
+	on := c.stat[i] == 1 && c.maxOn[i] > 0
+	if c.limits[i] != nil {
+		c.limits[i].Stop()
+		c.limits[i] = nil
+	}
+	if !on || c.closed {
+		return
+	}
+	since := c.onAt[i]
+	d := c.maxOn[i] - c.clock.Now().Sub(since)
+	if d < 0 {
+		d = 0
+	}
+	c.limits[i] = c.clock.AfterFunc(d, func() {
+		c.expireMaxOn(ch, since)
+	})
+}
+
+// expireMaxOn switches off a channel that has been on since since.
+func (c *Client) expireMaxOn(ch Channel, since time.Time) {
+	c.Lock()
+	defer c.unlock()
+	i := ch - 1
+	if c.stat[i] == 0 || !c.onAt[i].Equal(since) || c.maxOn[i] <= 0 {
+		return
+	}
+	_, err := c.commandOff(RequestOffOne, channelData(ch))
+	c.emit(EventMaxOnTime, ch, err)
+	if err != nil && !c.closed && c.stat[i] == 1 {
+		c.limits[i] = c.clock.AfterFunc(maxOnRetry, func() {
+			c.expireMaxOn(ch, since)
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package relay
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestClient_MaxOn(t *testing.T) {
+	c, board := newTestClient(t, DefaultBranchesLength)
+	clock := newFakeClock()
+	c.SetClock(clock)
+	var events []Event
+	c.SetEventHandler(func(e Event) {
+		events = append(events, e)
+	})
+	if err := c.SetMaxOn(1, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetMaxOn(2, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.OnAll(); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(30 * time.Second)
+	if err := c.FlipChannels(2); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(20 * time.Second)
+	if err := c.FlipChannels(2); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(10 * time.Second)
+	if board.get()&0xff != 0xfe {
+		t.Fatalf("board %#x, want channel 1 off", board.get())
+	}
+	if len(events) != 1 || events[0].Kind != EventMaxOnTime || events[0].Channel != 1 {
+		t.Fatalf("events %v", events)
+	}
+	if since, on := c.OnSince(2); !on || clock.Now().Sub(since) != 10*time.Second {
+		t.Fatalf("channel 2 on since %v %v", since, on)
+	}
+	clock.Advance(50 * time.Second)
+	if board.get()&0xff != 0xfc {
+		t.Fatalf("board %#x, want channel 2 off", board.get())
+	}
+}
+
+func TestClient_MaxOnRestore(t *testing.T) {
+	store := NewFileStore(filepath.Join(t.TempDir(), "relay.json"))
+	clock := newFakeClock()
+	c, _ := newTestClient(t, DefaultBranchesLength)
+	c.SetClock(clock)
+	c.SetStateStore(store)
+	if err := c.SetMaxOn(3, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.On(3); err != nil {
+		t.Fatal(err)
+	}
+
+	clock.Advance(40 * time.Minute)
+	c, board := newTestClient(t, DefaultBranchesLength)
+	c.SetClock(clock)
+	c.SetStateStore(store)
+	if err := c.Restore(RestoreCommanded); err != nil {
+		t.Fatal(err)
+	}
+	if c.MaxOn(3) != time.Hour || board.get() != 0x04 {
+		t.Fatalf("max on %v board %#x not restored", c.MaxOn(3), board.get())
+	}
+	clock.Advance(20 * time.Minute)
+	if board.get() != 0 {
+		t.Fatalf("board %#x, want channel 3 off after one hour", board.get())
+	}
+}
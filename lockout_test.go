@@ -0,0 +1,67 @@
+package relay
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestClient_Lockout(t *testing.T) {
+	c, board := newTestClient(t, DefaultBranchesLength)
+	store := NewFileStore(filepath.Join(t.TempDir(), "relay.json"))
+	c.SetStateStore(store)
+	if err := c.OnChannels(2, 3); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Lockout(3, "", "no owner"); err != ErrLockoutOwner {
+		t.Fatalf("err %v, want %v", err, ErrLockoutOwner)
+	}
+	if err := c.Lockout(3, "alice", "replacing pump"); err != nil {
+		t.Fatal(err)
+	}
+	if board.get() != 0x02 {
+		t.Fatalf("board %#x, want channel 3 off", board.get())
+	}
+
+	refused := []func() error{
+		func() error { return c.On(3) },
+		func() error { return c.OnNil(3) },
+		func() error { return c.FlipChannels(3) },
+		func() error { return c.SetAll(0xff) },
+		func() error { _, err := c.Pulse(3, time.Second); return err },
+		func() error { return c.OnAs("bob", 3) },
+		func() error { return c.ClearLockout(3, "bob") },
+	}
+	for k, do := range refused {
+		err := do()
+		if e, ok := err.(*LockoutError); !ok || e.Owner != "alice" {
+			t.Fatalf("command %d: err %v, want *LockoutError", k, err)
+		}
+	}
+	if err := c.OnAs("alice", 3); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.FlipAs("alice", 3); err != nil {
+		t.Fatal(err)
+	}
+
+	restarted, _ := newTestClient(t, DefaultBranchesLength)
+	restarted.SetStateStore(store)
+	if err := restarted.Restore(AdoptHardware); err != nil {
+		t.Fatal(err)
+	}
+	lockouts := restarted.Lockouts()
+	if len(lockouts) != 1 || lockouts[0].Channel != 3 || lockouts[0].Reason != "replacing pump" {
+		t.Fatalf("lockouts %+v not restored", lockouts)
+	}
+
+	if err := c.ClearLockout(3, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ClearLockout(3, "alice"); err != ErrNotLockedOut {
+		t.Fatalf("err %v, want %v", err, ErrNotLockedOut)
+	}
+	if err := c.On(3); err != nil {
+		t.Fatal(err)
+	}
+}
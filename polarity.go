@@ -0,0 +1,102 @@
+package relay
+
+// SetInverted sets the polarity of the channel. The load of an inverted
+// channel runs while the relay is released, for example when it is wired to
+// the normally closed contact. Only the load API (Energize, Deenergize,
+// LoadStatus) is affected; On, Off and Status still speak in relay terms.
+func (c *Client) SetInverted(ch Channel, inverted bool) error {
+	if err := c.check(ch); err != nil {
+		return err
+	}
+	c.Lock()
+	defer c.Unlock()
+	if inverted {
+		c.inverted |= ch.bit()
+	} else {
+		c.inverted &^= ch.bit()
+	}
+	return nil
+}
+
+// Inverted reports whether the channel has inverted polarity.
+func (c *Client) Inverted(ch Channel) bool {
+	c.Lock()
+	defer c.Unlock()
+	return c.inverted&ch.bit() != 0
+}
+
+// Energize switches the load of the channel on, the relay is released if
+// the channel is inverted.
+func (c *Client) Energize(ch Channel) error {
+	if c.Inverted(ch) {
+		return c.Off(ch)
+	}
+	return c.On(ch)
+}
+
+// Deenergize switches the load of the channel off.
+func (c *Client) Deenergize(ch Channel) error {
+	if c.Inverted(ch) {
+		return c.On(ch)
+	}
+	return c.Off(ch)
+}
+
+// EnergizeChannels switches the loads of the channels on. Channels of
+// different polarity are switched by two group commands: the normal
+// channels are closed first, then the inverted ones opened.
+func (c *Client) EnergizeChannels(chs ...Channel) error {
+	return c.load(RequestOnGroup, RequestOffGroup, chs...)
+}
+
+// DeenergizeChannels switches the loads of the channels off. The normal
+// channels are opened first, then the inverted ones closed.
+func (c *Client) DeenergizeChannels(chs ...Channel) error {
+	return c.load(RequestOffGroup, RequestOnGroup, chs...)
+}
+
+// load sends the group command code for the normal channels and inverse for
+// the inverted ones.
+func (c *Client) load(code, inverse byte, chs ...Channel) error {
+	mask, err := c.mask(chs...)
+	if err != nil {
+		return err
+	}
+	c.Lock()
+	inverted := c.inverted
+	c.Unlock()
+	for _, g := range []struct {
+		code byte
+		mask uint32
+	}{{code, mask &^ inverted}, {inverse, mask & inverted}} {
+		if g.mask == 0 {
+			continue
+		}
+		if _, err := c.command(g.code, maskData(g.mask)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadStatus is Status in load terms, 1 means the load runs.
+func (c *Client) LoadStatus() ([]byte, error) {
+	status, err := c.Status()
+	if err != nil {
+		return nil, err
+	}
+	c.Lock()
+	defer c.Unlock()
+	for i := range status {
+		status[i] ^= byte(c.inverted >> i & 1)
+	}
+	return status, nil
+}
+
+// LoadMask converts a mask of relay states to load states and back, BIT0 is
+// channel 1.
+func (c *Client) LoadMask(mask uint32) uint32 {
+	c.Lock()
+	defer c.Unlock()
+	return mask ^ c.inverted
+}
@@ -0,0 +1,46 @@
+package relay
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestClient_Energize(t *testing.T) {
+	c, board := newTestClient(t, DefaultBranchesLength)
+	if err := c.SetInverted(9, true); err != ErrBranchesLength {
+		t.Fatalf("err %v, want %v", err, ErrBranchesLength)
+	}
+	if err := c.SetInverted(2, true); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Energize(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Deenergize(2); err != nil {
+		t.Fatal(err)
+	}
+	if board.get() != 0x03 {
+		t.Fatalf("board %#x, want 0x3", board.get())
+	}
+	status, err := c.LoadStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(status, []byte{1, 0, 0, 0, 0, 0, 0, 0}) {
+		t.Fatalf("load status %v", status)
+	}
+
+	if err := c.EnergizeChannels(1, 2, 3); err != nil {
+		t.Fatal(err)
+	}
+	if board.get() != 0x05 || c.LoadMask(board.get()) != 0x07 {
+		t.Fatalf("board %#x", board.get())
+	}
+	if err := c.DeenergizeChannels(1, 2, 3); err != nil {
+		t.Fatal(err)
+	}
+	if board.get() != 0x02 || c.LoadMask(0) != 0x02 {
+		t.Fatalf("board %#x", board.get())
+	}
+}
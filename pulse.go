@@ -0,0 +1,320 @@
+package relay
+
+import (
+	"encoding/binary"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	// MaxPointDuration is the longest pulse the board accepts in a single
+	// point command, the duration is sent as 24 bit milliseconds.
+	MaxPointDuration = 0xffffff * time.Millisecond
+
+	// pointAdvance is how much earlier than the board the cache is updated
+	// when a point command ends.
+	pointAdvance = 10 * time.Millisecond
+	// pulseOverlap is how long before a chained segment ends the next one is
+	// sent, so the relay does not drop out between segments.
+	pulseOverlap = 500 * time.Millisecond
+)
+
+var (
+	ErrPulseDuration = errors.New("点动时间超出范围")
+	ErrPulseCanceled = errors.New("点动已被取消")
+)
+
+// Pulse is a running point command. Every point command, including the ones
+// sent by Pulse, PulseOff and their Nil variants, is tracked as a Pulse
+// until it ends or a conflicting command on the same channel cancels it.
+type Pulse struct {
+	// Channel is the pulsed channel.
+	Channel Channel
+
+	client *Client
+	code   byte
+	// end is written under both the client lock and mu.
+	end time.Time
+	// timer is guarded by the client lock.
+	timer Timer
+
+	mu   sync.Mutex
+	done chan struct{}
+	err  error
+}
+
+// Pulse closes the channel for d and opens it again. Durations longer than
+// MaxPointDuration are split into consecutive point commands. The returned
+// Pulse reports when the channel has been opened again.
+func (c *Client) Pulse(ch Channel, d time.Duration) (*Pulse, error) {
+	return c.pulse(RequestOnPoint, ch, d)
+}
+
+// PulseOff opens the channel for d and closes it again, see Pulse.
+func (c *Client) PulseOff(ch Channel, d time.Duration) (*Pulse, error) {
+	return c.pulse(RequestOffPoint, ch, d)
+}
+
+// PulseNil closes the channel for d without waiting for a reply. d must not
+// exceed MaxPointDuration; the pulse is tracked and reported by ActivePulse.
+func (c *Client) PulseNil(ch Channel, d time.Duration) error {
+	return c.pulseNil(RequestOnPointNil, ch, d)
+}
+
+// PulseOffNil opens the channel for d without waiting for a reply, see
+// PulseNil.
+func (c *Client) PulseOffNil(ch Channel, d time.Duration) error {
+	return c.pulseNil(RequestOffPointNil, ch, d)
+}
+
+// ActivePulse returns the pulse running on the channel, nil if there is none.
+func (c *Client) ActivePulse(ch Channel) *Pulse {
+	c.Lock()
+	defer c.Unlock()
+	return c.pulses[ch]
+}
+
+// Pulses returns all running pulses.
+func (c *Client) Pulses() []*Pulse {
+	c.Lock()
+	defer c.Unlock()
+	pulses := make([]*Pulse, 0, len(c.pulses))
+	for ch := Channel(1); byte(ch) <= c.length; ch++ {
+		if p, ok := c.pulses[ch]; ok {
+			pulses = append(pulses, p)
+		}
+	}
+	return pulses
+}
+
+// pulse starts a point command with reply.
+func (c *Client) pulse(code byte, ch Channel, d time.Duration) (*Pulse, error) {
+	if err := c.check(ch); err != nil {
+		return nil, err
+	}
+	if d < time.Millisecond {
+		return nil, ErrPulseDuration
+	}
+	c.acquire(code, pointData(ch, d))
+	defer c.unlock()
+	if err := c.admit(code, pointData(ch, d)); err != nil {
+		return nil, err
+	}
+	c.cancelPulse(ch)
+	p := c.newPulse(code, ch, d)
+	if err := p.next(d); err != nil {
+		return nil, err
+	}
+	c.pulses[ch] = p
+	return p, nil
+}
+
+// 点动处理无返回数据
+func (c *Client) pulseNil(code byte, ch Channel, d time.Duration) error {
+	if err := c.check(ch); err != nil {
+		return err
+	}
+	if d < time.Millisecond || d > MaxPointDuration {
+		return ErrPulseDuration
+	}
+	return c.sendNil(code, pointData(ch, d))
+}
+
+func (c *Client) newPulse(code byte, ch Channel, d time.Duration) *Pulse {
+	return &Pulse{
+		Channel: ch,
+		client:  c,
+		code:    code,
+		end:     c.clock.Now().Add(d),
+		done:    make(chan struct{}),
+	}
+}
+
+// cancelPulse stops tracking the pulse on the channel. Caller must hold the
+// lock.
+func (c *Client) cancelPulse(ch Channel) {
+	p, ok := c.pulses[ch]
+	if !ok {
+		return
+	}
+	p.timer.Stop()
+	delete(c.pulses, ch)
+	p.finish(ErrPulseCanceled)
+	c.emit(EventPulseCanceled, ch, ErrPulseCanceled)
+}
+
+// cancelPulses cancels the pulses on the channels in mask. Caller must hold
+// the lock.
+func (c *Client) cancelPulses(mask uint32) {
+	for ch := range c.pulses {
+		if mask&ch.bit() != 0 {
+			c.cancelPulse(ch)
+		}
+	}
+}
+
+// resuming returns the channels held off by a running off pulse, which
+// switch back on when the pulse ends. Caller must hold the lock.
+func (c *Client) resuming() uint32 {
+	mask := uint32(0)
+	for ch, p := range c.pulses {
+		if p.level() == 1 {
+			mask |= ch.bit()
+		}
+	}
+	return mask
+}
+
+// next sends the point command for the next segment of the pulse. Caller
+// must hold the client lock.
+func (p *Pulse) next(remaining time.Duration) error {
+	segment := remaining
+	if segment > MaxPointDuration {
+		segment = MaxPointDuration
+	}
+	if _, err := p.client.exchange(p.code, pointData(p.Channel, segment)); err != nil {
+		return err
+	}
+	if segment < remaining {
+		p.timer = p.client.clock.AfterFunc(segment-pulseOverlap, p.chain)
+	} else {
+		p.timer = p.client.clock.AfterFunc(pointDelay(segment), p.expire)
+	}
+	return nil
+}
+
+// restart sends the pulse again so that it ends d from now. Caller must hold
+// the client lock.
+func (p *Pulse) restart(d time.Duration) error {
+	c := p.client
+	if c.pulses[p.Channel] != p {
+		return ErrPulseCanceled
+	}
+	if err := c.admit(p.code, pointData(p.Channel, d)); err != nil {
+		return err
+	}
+	p.timer.Stop()
+	p.mu.Lock()
+	p.end = c.clock.Now().Add(d)
+	p.mu.Unlock()
+	if err := p.next(d); err != nil {
+		delete(c.pulses, p.Channel)
+		p.finish(err)
+		c.emit(EventPulseCanceled, p.Channel, err)
+		return err
+	}
+	return nil
+}
+
+// chain continues a pulse longer than MaxPointDuration.
+func (p *Pulse) chain() {
+	c := p.client
+	c.Lock()
+	defer c.unlock()
+	if c.pulses[p.Channel] != p {
+		return
+	}
+	if err := p.next(p.end.Sub(c.clock.Now())); err != nil {
+		delete(c.pulses, p.Channel)
+		p.finish(err)
+		c.emit(EventPulseCanceled, p.Channel, err)
+	}
+}
+
+// expire updates the cache when the board ends the pulse.
+func (p *Pulse) expire() {
+	c := p.client
+	c.Lock()
+	if c.pulses[p.Channel] != p {
+		c.Unlock()
+		return
+	}
+	delete(c.pulses, p.Channel)
+	state := c.cached() &^ p.Channel.bit()
+	if p.level() == 1 {
+		state |= p.Channel.bit()
+	}
+	c.setStat(state)
+	c.emit(EventPulseEnded, p.Channel, nil)
+	c.unlock()
+	p.finish(nil)
+}
+
+// level is the state of the channel after the pulse.
+func (p *Pulse) level() uint16 {
+	if p.code == RequestOffPoint || p.code == RequestOffPointNil {
+		return 1
+	}
+	return 0
+}
+
+func (p *Pulse) finish(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	select {
+	case <-p.done:
+		return
+	default:
+	}
+	p.err = err
+	close(p.done)
+}
+
+// Done returns a channel that is closed when the pulse has ended or was
+// canceled.
+func (p *Pulse) Done() <-chan struct{} {
+	return p.done
+}
+
+// Err returns ErrPulseCanceled or the error that ended a chained pulse early,
+// nil while the pulse is running or after it ended normally.
+func (p *Pulse) Err() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err
+}
+
+// Wait blocks until the pulse has ended and returns its error.
+func (p *Pulse) Wait() error {
+	<-p.done
+	return p.Err()
+}
+
+// Remaining returns the time left until the pulse ends, zero once it ended.
+func (p *Pulse) Remaining() time.Duration {
+	select {
+	case <-p.done:
+		return 0
+	default:
+	}
+	p.mu.Lock()
+	end := p.end
+	p.mu.Unlock()
+	if d := end.Sub(p.client.clock.Now()); d > 0 {
+		return d
+	}
+	return 0
+}
+
+// Cancel ends the pulse early by switching the channel back to the state it
+// has after the pulse.
+func (p *Pulse) Cancel() error {
+	select {
+	case <-p.done:
+		return nil
+	default:
+	}
+	if p.level() == 0 {
+		return p.client.Off(p.Channel)
+	}
+	return p.client.On(p.Channel)
+}
+
+// pointData is the data of a point command, 24 bit milliseconds followed by
+// the channel.
+func pointData(ch Channel, d time.Duration) []byte {
+	data := make([]byte, 4)
+	binary.BigEndian.PutUint32(data, uint32(d/time.Millisecond)<<8|uint32(ch))
+	return data
+}
@@ -0,0 +1,100 @@
+package relay
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestClient_Pulse(t *testing.T) {
+	c, board := newTestClient(t, DefaultBranchesLength)
+	if _, err := c.Pulse(9, time.Second); err != ErrBranchesLength {
+		t.Fatalf("err %v, want %v", err, ErrBranchesLength)
+	}
+	if _, err := c.Pulse(1, time.Microsecond); err != ErrPulseDuration {
+		t.Fatalf("err %v, want %v", err, ErrPulseDuration)
+	}
+
+	p, err := c.Pulse(3, 20*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if board.get() != 1<<2 || c.GetStats()[2] != 1 {
+		t.Fatalf("channel 3 not closed: board %#x cache %v", board.get(), c.GetStats())
+	}
+	select {
+	case <-p.Done():
+	case <-time.After(time.Second):
+		t.Fatal("pulse did not end")
+	}
+	if err := p.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if c.GetStats()[2] != 0 {
+		t.Fatalf("channel 3 still closed in cache: %v", c.GetStats())
+	}
+}
+
+func TestPointDelay(t *testing.T) {
+	if d := pointDelay(pointTime([]byte{0, 0, 5, 1})); d != 0 {
+		t.Fatalf("delay %v, want 0", d)
+	}
+	if d := pointDelay(pointTime([]byte{0, 0x03, 0xe8, 1})); d != time.Second-pointAdvance {
+		t.Fatalf("delay %v, want %v", d, time.Second-pointAdvance)
+	}
+}
+
+func TestClient_PulseCanceled(t *testing.T) {
+	c, _ := newTestClient(t, DefaultBranchesLength)
+	clock := newFakeClock()
+	c.SetClock(clock)
+	var (
+		mu     sync.Mutex
+		events []Event
+	)
+	c.SetEventHandler(func(e Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, e)
+	})
+
+	p, err := c.Pulse(2, 50*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.ActivePulse(2) != p || p.Remaining() <= 0 {
+		t.Fatalf("pulse not tracked: %v %v", c.ActivePulse(2), p.Remaining())
+	}
+	if err := c.On(2); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Wait(); err != ErrPulseCanceled {
+		t.Fatalf("err %v, want %v", err, ErrPulseCanceled)
+	}
+	if c.ActivePulse(2) != nil || p.Remaining() != 0 {
+		t.Fatal("canceled pulse still tracked")
+	}
+	clock.Advance(80 * time.Millisecond)
+	if c.GetStats()[1] != 1 {
+		t.Fatalf("stale pulse overwrote channel 2: %v", c.GetStats())
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(events) != 1 || events[0].Kind != EventPulseCanceled || events[0].Channel != 2 {
+		t.Fatalf("events %v", events)
+	}
+
+	if err := c.PulseOffNil(5, 20*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	mu.Unlock()
+	p = c.ActivePulse(5)
+	clock.Advance(20 * time.Millisecond)
+	mu.Lock()
+	if err := p.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if c.GetStats()[4] != 1 || events[1].Kind != EventPulseEnded {
+		t.Fatalf("cache %v events %v", c.GetStats(), events)
+	}
+}
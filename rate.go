@@ -0,0 +1,157 @@
+package relay
+
+import (
+	"fmt"
+	"time"
+)
+
+// RateLimit limits how often a channel may change its state. Safety paths
+// that switch a channel off, such as max on-time, the watchdog and the
+// release and expiry of holds and leases, are never limited.
+type RateLimit struct {
+	// MinInterval is the minimum time between two state changes.
+	MinInterval time.Duration
+	// MaxChanges is the maximum number of state changes within Window.
+	MaxChanges int
+	Window     time.Duration
+	// Defer makes commands wait until the change is allowed instead of
+	// failing with a *RateLimitError.
+	Defer bool
+}
+
+// RateLimitError is returned when a command would change a channel more
+// often than its RateLimit allows. It is returned before any frame is sent.
+type RateLimitError struct {
+	Channel Channel
+	// Wait is how long until the change would be allowed.
+	Wait time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("第%d路切换过于频繁,需等待 %v", e.Channel, e.Wait)
+}
+
+// SetRateLimit sets the switching rate limit of the channel, the zero
+// RateLimit removes it.
+func (c *Client) SetRateLimit(ch Channel, limit RateLimit) error {
+	if err := c.check(ch); err != nil {
+		return err
+	}
+	c.Lock()
+	defer c.Unlock()
+	c.rates[ch-1] = limit
+	c.changes[ch-1] = nil
+	return nil
+}
+
+// RateLimit returns the switching rate limit of the channel.
+func (c *Client) RateLimit(ch Channel) RateLimit {
+	if c.check(ch) != nil {
+		return RateLimit{}
+	}
+	c.Lock()
+	defer c.Unlock()
+	return c.rates[ch-1]
+}
+
+// acquire locks the client and waits, without holding the lock, until the
+// changes of the command are allowed on the channels with a deferring
+// RateLimit. It returns with the lock held.
+func (c *Client) acquire(code byte, data []byte) {
+	c.Lock()
+	for {
+		from := c.cached()
+		changed := (apply(code, data, from) ^ from) & c.full()
+		wait := time.Duration(0)
+		for ch := Channel(1); byte(ch) <= c.length; ch++ {
+			if changed&ch.bit() != 0 && c.rates[ch-1].Defer {
+				if d := c.rateWait(ch); d > wait {
+					wait = d
+				}
+			}
+		}
+		// a latched client refuses the command right away
+		if wait <= 0 || c.Latched() {
+			return
+		}
+		c.Unlock()
+		c.clock.Sleep(wait)
+		c.Lock()
+	}
+}
+
+// checkRates checks the channels changed between from and to. Caller must
+// hold the lock.
+func (c *Client) checkRates(from, to uint32) error {
+	changed := from ^ to
+	if c.exempt {
+		changed &= to
+	}
+	for ch := Channel(1); byte(ch) <= c.length; ch++ {
+		if changed&ch.bit() == 0 {
+			continue
+		}
+		if d := c.rateWait(ch); d > 0 {
+			return &RateLimitError{Channel: ch, Wait: d}
+		}
+	}
+	return nil
+}
+
+// commandOff sends a command opening channels for a safety path: max
+// on-time, the watchdog and the release and expiry of holds and leases.
+// Rate limits neither reject nor defer the channels it opens. Caller must
+// hold the lock.
+func (c *Client) commandOff(code byte, data []byte) (uint32, error) {
+	c.exempt = true
+	defer func() {
+		c.exempt = false
+	}()
+	return c.commandLocked(code, data)
+}
+
+// offGroup opens the channels of the mask with commandOff.
+func (c *Client) offGroup(mask uint32) (uint32, error) {
+	c.Lock()
+	defer c.unlock()
+	return c.commandOff(RequestOffGroup, maskData(mask))
+}
+
+// rateWait returns how long until the channel may change. Caller must hold
+// the lock.
+func (c *Client) rateWait(ch Channel) time.Duration {
+	i := ch - 1
+	limit := c.rates[i]
+	changes := c.changes[i]
+	if len(changes) == 0 {
+		return 0
+	}
+	now := c.clock.Now()
+	wait := limit.MinInterval - now.Sub(changes[len(changes)-1])
+	if limit.MaxChanges > 0 && len(changes) >= limit.MaxChanges {
+		oldest := changes[len(changes)-limit.MaxChanges]
+		if d := limit.Window - now.Sub(oldest); d > wait {
+			wait = d
+		}
+	}
+	return wait
+}
+
+// record remembers a state change of a rate limited channel. Caller must
+// hold the lock.
+func (c *Client) record(ch Channel, at time.Time) {
+	i := ch - 1
+	limit := c.rates[i]
+	if limit == (RateLimit{}) {
+		return
+	}
+	keep := limit.MaxChanges
+	if keep < 1 {
+		keep = 1
+	}
+	changes := append(c.changes[i], at)
+	if len(changes) > keep {
+		changes = append(changes[:0], changes[len(changes)-keep:]...)
+	}
+	c.changes[i] = changes
+}
@@ -0,0 +1,109 @@
+package relay
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClient_RateLimit(t *testing.T) {
+	c, board := newTestClient(t, DefaultBranchesLength)
+	clock := newFakeClock()
+	c.SetClock(clock)
+	err := c.SetRateLimit(1, RateLimit{
+		MinInterval: time.Second,
+		MaxChanges:  3,
+		Window:      10 * time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.On(1); err != nil {
+		t.Fatal(err)
+	}
+	n := len(board.frames)
+	err = c.FlipNil(1)
+	if e, ok := err.(*RateLimitError); !ok || e.Channel != 1 || e.Wait != time.Second {
+		t.Fatalf("err %v, want *RateLimitError", err)
+	}
+	if len(board.frames) != n {
+		t.Fatal("frame sent for a rejected command")
+	}
+	if err := c.On(1); err != nil {
+		t.Fatalf("command without change rejected: %v", err)
+	}
+	clock.Advance(time.Second)
+	if err := c.Off(1); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Second)
+	if err := c.OnChannels(1, 2); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Second)
+	err = c.Off(1)
+	if e, ok := err.(*RateLimitError); !ok || e.Wait != 7*time.Second {
+		t.Fatalf("err %v, want window exhausted", err)
+	}
+}
+
+func TestClient_RateLimitDefer(t *testing.T) {
+	c, board := newTestClient(t, DefaultBranchesLength)
+	clock := newFakeClock()
+	c.SetClock(clock)
+	if err := c.SetRateLimit(2, RateLimit{MinInterval: time.Minute, Defer: true}); err != nil {
+		t.Fatal(err)
+	}
+	start := clock.Now()
+	for i := 0; i < 3; i++ {
+		if err := c.Flip(2); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if d := clock.Now().Sub(start); d != 2*time.Minute {
+		t.Fatalf("deferred for %v, want %v", d, 2*time.Minute)
+	}
+	if board.get() != 0x02 {
+		t.Fatalf("board %#x, want %#x", board.get(), 0x02)
+	}
+}
+
+func TestClient_RateLimitSafetyOff(t *testing.T) {
+	c, board := newTestClient(t, DefaultBranchesLength)
+	clock := newFakeClock()
+	c.SetClock(clock)
+	for ch := Channel(1); ch <= 3; ch++ {
+		if err := c.SetRateLimit(ch, RateLimit{MinInterval: 5 * time.Minute, Defer: ch == 3}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	retries := 0
+	c.SetEventHandler(func(e Event) {
+		if e.Kind == EventMaxOnTime {
+			retries++
+		}
+	})
+	if err := c.SetMaxOn(1, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.OnChannels(1, 2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.StartWatchdog(30*time.Second, time.Second, 2); err != nil {
+		t.Fatal(err)
+	}
+	h, err := c.Acquire(3, "fan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := clock.Now()
+	if err := c.Release(h); err != nil {
+		t.Fatal(err)
+	}
+	if clock.Now() != start || board.get() != 0x03 {
+		t.Fatalf("release deferred until %v, board %#x", clock.Now(), board.get())
+	}
+	clock.Advance(2 * time.Minute)
+	if board.get() != 0 || retries != 1 {
+		t.Fatalf("board %#x after %d max-on events", board.get(), retries)
+	}
+}
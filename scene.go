@@ -0,0 +1,170 @@
+package relay
+
+import (
+	"errors"
+	"sort"
+)
+
+var (
+	ErrSceneName    = errors.New("场景需要名称")
+	ErrUnknownScene = errors.New("未定义的场景")
+)
+
+// Scene is a named state of some or all channels, e.g. "night" or
+// "cleaning". Scenes are saved with the state of the client.
+type Scene struct {
+	Name string `json:"name"`
+	// Mask selects the channels the scene sets. The other channels are
+	// "don't care" and left untouched by ActivateScene. BIT0 is channel 1.
+	Mask uint32 `json:"mask"`
+	// State is the relay state of the selected channels.
+	State uint32 `json:"state"`
+}
+
+// SaveScene stores the scene, replacing a scene with the same name.
+func (c *Client) SaveScene(s Scene) error {
+	if s.Name == "" {
+		return ErrSceneName
+	}
+	c.Lock()
+	defer c.unlock()
+	c.saveScene(s)
+	return nil
+}
+
+// saveScene stores the scene. Caller must hold the lock.
+func (c *Client) saveScene(s Scene) {
+	s.Mask &= c.full()
+	s.State &= s.Mask
+	if c.scenes == nil {
+		c.scenes = make(map[string]Scene)
+	}
+	c.scenes[s.Name] = s
+}
+
+// CaptureScene reads the board and saves the state of the channels as a
+// scene. Without channels all channels of the board are captured.
+func (c *Client) CaptureScene(name string, chs ...Channel) (Scene, error) {
+	mask, err := c.mask(chs...)
+	if err != nil {
+		return Scene{}, err
+	}
+	if len(chs) == 0 {
+		mask = c.full()
+	}
+	if name == "" {
+		return Scene{}, ErrSceneName
+	}
+	c.Lock()
+	defer c.unlock()
+	status, err := c.readStatusLocked()
+	if err != nil {
+		return Scene{}, err
+	}
+	c.setStat(status)
+	s := Scene{Name: name, Mask: mask, State: status & mask}
+	c.saveScene(s)
+	return s, nil
+}
+
+// Scene returns the saved scene.
+func (c *Client) Scene(name string) (Scene, bool) {
+	c.Lock()
+	defer c.Unlock()
+	s, ok := c.scenes[name]
+	return s, ok
+}
+
+// Scenes returns the saved scenes sorted by name.
+func (c *Client) Scenes() []Scene {
+	c.Lock()
+	defer c.Unlock()
+	return c.sortedScenes()
+}
+
+// DeleteScene removes the scene.
+func (c *Client) DeleteScene(name string) {
+	c.Lock()
+	defer c.unlock()
+	delete(c.scenes, name)
+}
+
+// ActivateScene switches the channels of the scene with a single frame: a
+// group command if the channels only need to be closed or only opened,
+// RunCMD otherwise. RunCMD writes back the state of the "don't care"
+// channels read just before under the client lock; if one of them has a
+// running pulse, an open and a close group command are sent instead so the
+// pulse is not canceled.
+func (c *Client) ActivateScene(name string) error {
+	s, ok := c.Scene(name)
+	if !ok {
+		return ErrUnknownScene
+	}
+	// wait for deferring rate limits on the channels the scene changes
+	c.Lock()
+	changes := (c.cached() ^ s.State) & s.Mask
+	c.Unlock()
+	c.acquire(RequestFlipGroup, maskData(changes))
+	defer c.unlock()
+	current, err := c.update(c.transact(RequestReadStatus, []byte{0, 0, 0, 0}))
+	if err != nil {
+		return err
+	}
+	on := s.State &^ current
+	off := current & s.Mask &^ s.State
+	var frames []frame
+	switch {
+	case on == 0 && off == 0:
+	case off == 0:
+		frames = []frame{{RequestOnGroup, on}}
+	case on == 0:
+		frames = []frame{{RequestOffGroup, off}}
+	case c.pulsing(c.full() &^ s.Mask):
+		frames = []frame{{RequestOffGroup, off}, {RequestOnGroup, on}}
+	default:
+		frames = []frame{{RequestRunCMD, current&^s.Mask | s.State}}
+	}
+	for _, f := range frames {
+		status, err := c.commandLocked(f.code, maskData(f.mask))
+		if err != nil {
+			return err
+		}
+		current = status
+	}
+	if current&s.Mask != s.State {
+		return ErrReturnResult
+	}
+	return nil
+}
+
+// frame is a group or RunCMD command.
+type frame struct {
+	code byte
+	mask uint32
+}
+
+// pulsing reports whether a pulse runs on one of the channels. Caller must
+// hold the lock.
+func (c *Client) pulsing(mask uint32) bool {
+	for ch := range c.pulses {
+		if mask&ch.bit() != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedScenes returns the scenes sorted by name. Caller must hold the lock.
+func (c *Client) sortedScenes() []Scene {
+	if len(c.scenes) == 0 {
+		return nil
+	}
+	scenes := make([]Scene, 0, len(c.scenes))
+	for _, s := range c.scenes {
+		scenes = append(scenes, s)
+	}
+	sort.Slice(scenes, func(i, j int) bool {
+		return scenes[i].Name < scenes[j].Name
+	})
+	return scenes
+}
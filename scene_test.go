@@ -0,0 +1,83 @@
+package relay
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestClient_ActivateScene(t *testing.T) {
+	c, board := newTestClient(t, DefaultBranchesLength)
+	if err := c.ActivateScene("night"); err != ErrUnknownScene {
+		t.Fatalf("err %v, want %v", err, ErrUnknownScene)
+	}
+	if err := c.SaveScene(Scene{Name: "night", Mask: 0x0f, State: 0x03}); err != nil {
+		t.Fatal(err)
+	}
+
+	// only closing needed: one group frame
+	board.state = 0x80
+	if err := c.ActivateScene("night"); err != nil {
+		t.Fatal(err)
+	}
+	if board.get() != 0x83 || board.frames[len(board.frames)-1] != RequestOnGroup {
+		t.Fatalf("board %#x frames %v", board.get(), board.frames)
+	}
+
+	// closing and opening: one RunCMD frame keeping channel 8
+	board.state = 0x8c
+	n := len(board.frames)
+	if err := c.ActivateScene("night"); err != nil {
+		t.Fatal(err)
+	}
+	if board.get() != 0x83 || len(board.frames) != n+2 || board.frames[n+1] != RequestRunCMD {
+		t.Fatalf("board %#x frames %v", board.get(), board.frames[n:])
+	}
+
+	// a pulse on a don't care channel survives the scene
+	p, err := c.Pulse(6, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	board.state = 0xac
+	n = len(board.frames)
+	if err := c.ActivateScene("night"); err != nil {
+		t.Fatal(err)
+	}
+	if board.get() != 0xa3 || len(board.frames) != n+3 || c.ActivePulse(6) != p {
+		t.Fatalf("board %#x frames %v", board.get(), board.frames[n:])
+	}
+}
+
+func TestClient_CaptureScene(t *testing.T) {
+	c, board := newTestClient(t, DefaultBranchesLength)
+	store := NewFileStore(filepath.Join(t.TempDir(), "relay.json"))
+	c.SetStateStore(store)
+	board.state = 0x15
+	s, err := c.CaptureScene("cleaning", 1, 2, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Mask != 0x07 || s.State != 0x05 {
+		t.Fatalf("scene %+v", s)
+	}
+	if _, err := c.CaptureScene("all"); err != nil {
+		t.Fatal(err)
+	}
+	if scenes := c.Scenes(); len(scenes) != 2 || scenes[0].Name != "all" || scenes[0].Mask != 0xff {
+		t.Fatalf("scenes %+v", scenes)
+	}
+
+	restored, _ := newTestClient(t, DefaultBranchesLength)
+	restored.SetStateStore(store)
+	if err := restored.Restore(AdoptHardware); err != nil {
+		t.Fatal(err)
+	}
+	if s, ok := restored.Scene("cleaning"); !ok || s.State != 0x05 {
+		t.Fatalf("restored scene %+v", s)
+	}
+	c.DeleteScene("all")
+	if _, ok := c.Scene("all"); ok {
+		t.Fatal("scene not deleted")
+	}
+}
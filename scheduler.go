@@ -0,0 +1,349 @@
+package relay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	ErrJobName   = errors.New("任务名称为空或已存在")
+	ErrJobAction = errors.New("任务动作只能是 on/off/flip/pulse/pulse_off/scene")
+	ErrJobTime   = errors.New("任务时间已过")
+)
+
+// MissedPolicy decides what a Scheduler does with runs that were missed,
+// because the process was down or its timer fired late.
+type MissedPolicy int
+
+const (
+	// MissedSkip drops missed runs. A run up to MissedTolerance late
+	// still happens.
+	MissedSkip MissedPolicy = iota
+	// MissedRunOnce runs the action once for any number of missed runs.
+	MissedRunOnce
+	// MissedRunAll runs the action once for every missed run, at most
+	// maxCatchUp times.
+	MissedRunAll
+)
+
+// MissedTolerance is how late a run may happen and still not count as
+// missed.
+const MissedTolerance = time.Minute
+
+// maxCatchUp limits the runs made up for by MissedRunAll.
+const maxCatchUp = 1000
+
+// Job is an action run by a Scheduler whenever its trigger fires.
+type Job struct {
+	Name    string
+	Trigger Trigger
+	// Action is a sequence step switching channels: on, off, flip, pulse,
+	// pulse_off or scene.
+	Action SequenceStep
+	Missed MissedPolicy
+	// LastRun is the last time the job ran before a restart. Runs between
+	// LastRun and the start of the scheduler are missed runs. If it is zero,
+	// the last run saved with the state of the client is used, see
+	// Client.SetStateStore; without one nothing was missed.
+	LastRun time.Time
+}
+
+// JobInfo describes a scheduled job.
+type JobInfo struct {
+	Job
+	// Next is the next run, zero if the trigger will not fire again.
+	Next time.Time
+	// Runs counts the runs of the action, Skipped the dropped missed runs.
+	Runs    int
+	Skipped int
+	// Err is the error of the last run.
+	Err error
+}
+
+type job struct {
+	info  JobInfo
+	timer Timer
+	// finished is set once the trigger will not fire again
+	finished bool
+}
+
+// Scheduler runs actions on a client at the times given by triggers.
+type Scheduler struct {
+	client *Client
+
+	mu      sync.Mutex
+	jobs    map[string]*job
+	started bool
+}
+
+// NewScheduler creates a stopped scheduler for the client. It uses the
+// clock of the client.
+func NewScheduler(c *Client) *Scheduler {
+	return &Scheduler{client: c, jobs: make(map[string]*job)}
+}
+
+// Add schedules the job. A job added to a started scheduler is armed right
+// away.
+func (s *Scheduler) Add(j Job) error {
+	if j.Name == "" {
+		return ErrJobName
+	}
+	switch j.Action.Action {
+	case ActionOn, ActionOff, ActionFlip, ActionPulse, ActionPulseOff, ActionScene:
+	default:
+		return ErrJobAction
+	}
+	if err := validateSteps([]SequenceStep{j.Action}); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	if _, ok := s.jobs[j.Name]; ok {
+		s.mu.Unlock()
+		return ErrJobName
+	}
+	jb := &job{info: JobInfo{Job: j}}
+	s.jobs[j.Name] = jb
+	due := s.started && s.arm(jb)
+	s.mu.Unlock()
+	if due {
+		s.fire(jb)
+	}
+	return nil
+}
+
+// AddCron schedules the action with a cron expression matched in loc, see
+// ParseCron.
+func (s *Scheduler) AddCron(name, spec string, loc *time.Location, action SequenceStep, missed MissedPolicy) error {
+	cron, err := ParseCron(spec, loc)
+	if err != nil {
+		return err
+	}
+	return s.Add(Job{Name: name, Trigger: cron, Action: action, Missed: missed})
+}
+
+// At schedules the action once at the time. On a started scheduler the time
+// must lie in the future, otherwise ErrJobTime is returned. Before Start a
+// past time is a run missed while the process was down and is handled by
+// the MissedPolicy.
+func (s *Scheduler) At(name string, t time.Time, action SequenceStep, missed MissedPolicy) error {
+	if t.IsZero() {
+		return ErrJobTime
+	}
+	s.mu.Lock()
+	late := s.started && !t.After(s.client.clock.Now())
+	s.mu.Unlock()
+	if late {
+		return ErrJobTime
+	}
+	return s.Add(Job{Name: name, Trigger: Once(t), Action: action, Missed: missed})
+}
+
+// Remove unschedules the job.
+func (s *Scheduler) Remove(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	jb, ok := s.jobs[name]
+	if ok {
+		if jb.timer != nil {
+			jb.timer.Stop()
+		}
+		delete(s.jobs, name)
+		s.client.saveRun(name, time.Time{})
+	}
+	return ok
+}
+
+// Job returns the state of the job.
+func (s *Scheduler) Job(name string) (JobInfo, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	jb, ok := s.jobs[name]
+	if !ok {
+		return JobInfo{}, false
+	}
+	return jb.info, true
+}
+
+// Jobs returns the state of all jobs sorted by their next run; jobs that
+// will not run again come last.
+func (s *Scheduler) Jobs() []JobInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	jobs := make([]JobInfo, 0, len(s.jobs))
+	for _, jb := range s.jobs {
+		jobs = append(jobs, jb.info)
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		a, b := jobs[i].Next, jobs[j].Next
+		if a.IsZero() != b.IsZero() {
+			return b.IsZero()
+		}
+		if !a.Equal(b) {
+			return a.Before(b)
+		}
+		return jobs[i].Name < jobs[j].Name
+	})
+	return jobs
+}
+
+// Start arms the jobs. Jobs that missed runs since their LastRun are
+// handled according to their MissedPolicy before Start returns. Restore the
+// state of the client before Start so the saved last runs are known.
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	if s.started {
+		s.mu.Unlock()
+		return
+	}
+	s.started = true
+	var due []*job
+	for _, jb := range s.jobs {
+		if jb.info.LastRun.IsZero() && jb.info.Next.IsZero() {
+			jb.info.LastRun = s.client.lastRun(jb.info.Name)
+		}
+		if s.arm(jb) {
+			due = append(due, jb)
+		}
+	}
+	s.mu.Unlock()
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].info.Next.Before(due[j].info.Next)
+	})
+	for _, jb := range due {
+		s.fire(jb)
+	}
+}
+
+// Stop disarms the jobs, a running action is not interrupted.
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.started = false
+	for _, jb := range s.jobs {
+		if jb.timer != nil {
+			jb.timer.Stop()
+			jb.timer = nil
+		}
+	}
+}
+
+// arm computes the next run of the job and starts its timer. It reports
+// whether the run is already due. Caller must hold the lock.
+func (s *Scheduler) arm(jb *job) bool {
+	if jb.finished {
+		return false
+	}
+	now := s.client.clock.Now()
+	if jb.info.Next.IsZero() {
+		from := jb.info.LastRun
+		if from.IsZero() {
+			from = now
+			// a one-shot that never ran is due at its time, also if it
+			// passed while the process was down
+			if _, ok := jb.info.Trigger.(Once); ok {
+				from = time.Time{}
+			}
+		}
+		jb.info.Next = jb.info.Trigger.Next(from)
+	}
+	if jb.info.Next.IsZero() {
+		return false
+	}
+	d := jb.info.Next.Sub(now)
+	if d <= 0 {
+		return true
+	}
+	jb.timer = s.client.clock.AfterFunc(d, func() {
+		s.fire(jb)
+	})
+	return false
+}
+
+// fire runs the due runs of the job according to its MissedPolicy and arms
+// the next one.
+func (s *Scheduler) fire(jb *job) {
+	s.mu.Lock()
+	if !s.started || s.jobs[jb.info.Name] != jb || jb.info.Next.IsZero() {
+		s.mu.Unlock()
+		return
+	}
+	now := s.client.clock.Now()
+	// count the due runs, the last one is on time within the tolerance
+	due, onTime := 0, false
+	t := jb.info.Next
+	for !t.IsZero() && !t.After(now) {
+		due++
+		onTime = now.Sub(t) <= MissedTolerance
+		t = jb.info.Trigger.Next(t)
+	}
+	runs := due
+	switch jb.info.Missed {
+	case MissedSkip:
+		runs = 0
+		if onTime {
+			runs = 1
+		}
+	case MissedRunOnce:
+		if runs > 1 {
+			runs = 1
+		}
+	case MissedRunAll:
+		if runs > maxCatchUp {
+			runs = maxCatchUp
+		}
+	}
+	jb.info.Skipped += due - runs
+	jb.info.Next = t
+	jb.finished = t.IsZero()
+	action := jb.info.Action
+	s.mu.Unlock()
+
+	var err error
+	for i := 0; i < runs; i++ {
+		r := &sequenceRun{client: s.client, seq: jb.info.Name}
+		err = r.step(context.Background(), action)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if runs > 0 {
+		jb.info.Runs += runs
+		jb.info.LastRun = now
+		jb.info.Err = err
+		s.client.saveRun(jb.info.Name, now)
+	}
+	if s.started && s.jobs[jb.info.Name] == jb && s.arm(jb) {
+		// the action took until the next run
+		go s.fire(jb)
+	}
+}
+
+// lastRun returns the saved last run of the job.
+func (c *Client) lastRun(name string) time.Time {
+	c.Lock()
+	defer c.Unlock()
+	return c.jobs[name]
+}
+
+// saveRun records the last run of the job with the state of the client, a
+// zero time removes it.
+func (c *Client) saveRun(name string, t time.Time) {
+	c.Lock()
+	defer c.unlock()
+	if t.IsZero() {
+		delete(c.jobs, name)
+		return
+	}
+	if c.jobs == nil {
+		c.jobs = make(map[string]time.Time)
+	}
+	c.jobs[name] = t
+}
+
+func (j JobInfo) String() string {
+	return fmt.Sprintf("%s %s %s next %v", j.Name, j.Trigger, j.Action, j.Next)
+}
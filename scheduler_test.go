@@ -0,0 +1,200 @@
+package relay
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestScheduler(t *testing.T) {
+	c, board := newTestClient(t, DefaultBranchesLength)
+	clock := newFakeClock() // 2021-06-01 00:00 UTC
+	c.SetClock(clock)
+	s := NewScheduler(c)
+	lamp := SequenceStep{Action: ActionOn, Channels: []Channel{1}}
+	if err := s.AddCron("lamp.on", "0 18 * * *", time.UTC, lamp, MissedSkip); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddCron("lamp.off", "0 23 * * *", time.UTC, SequenceStep{Action: ActionOff, Channels: []Channel{1}}, MissedSkip); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.At("bell", clock.Now().Add(90*time.Minute), SequenceStep{Action: ActionPulse, Channels: []Channel{2}, Duration: Duration(time.Second)}, MissedSkip); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddCron("lamp.on", "* * * * *", time.UTC, lamp, MissedSkip); err != ErrJobName {
+		t.Fatalf("err %v, want %v", err, ErrJobName)
+	}
+	if err := s.At("wait", clock.Now(), SequenceStep{Action: ActionWait, Duration: Duration(time.Second)}, MissedSkip); err != ErrJobAction {
+		t.Fatalf("err %v, want %v", err, ErrJobAction)
+	}
+	s.Start()
+	defer s.Stop()
+
+	jobs := s.Jobs()
+	if len(jobs) != 3 || jobs[0].Name != "bell" || jobs[1].Name != "lamp.on" || jobs[2].Name != "lamp.off" {
+		t.Fatalf("jobs %v", jobs)
+	}
+	clock.Advance(2 * time.Hour)
+	if board.get() != 0x02 {
+		t.Fatalf("board %#x after the bell", board.get())
+	}
+	clock.Advance(17 * time.Hour)
+	if board.get()&0x01 == 0 {
+		t.Fatalf("lamp off at 19:00, board %#x", board.get())
+	}
+	clock.Advance(5 * time.Hour)
+	if board.get()&0x01 != 0 {
+		t.Fatalf("lamp on at 24:00, board %#x", board.get())
+	}
+	bell, _ := s.Job("bell")
+	on, _ := s.Job("lamp.on")
+	if bell.Runs != 1 || !bell.Next.IsZero() || on.Runs != 1 || !on.Next.Equal(time.Date(2021, 6, 2, 18, 0, 0, 0, time.UTC)) {
+		t.Fatalf("bell %+v lamp.on %+v", bell, on)
+	}
+	if jobs := s.Jobs(); jobs[2].Name != "bell" {
+		t.Fatalf("jobs %v, want the finished job last", jobs)
+	}
+
+	if !s.Remove("lamp.on") || s.Remove("lamp.on") {
+		t.Fatal("remove")
+	}
+	clock.Advance(24 * time.Hour)
+	if board.get()&0x01 != 0 {
+		t.Fatal("removed job ran")
+	}
+}
+
+func TestScheduler_Missed(t *testing.T) {
+	for _, tt := range []struct {
+		policy        MissedPolicy
+		runs, skipped int
+	}{
+		{MissedSkip, 0, 3},
+		{MissedRunOnce, 1, 2},
+		{MissedRunAll, 3, 0},
+	} {
+		c, board := newTestClient(t, DefaultBranchesLength)
+		clock := newFakeClock()
+		c.SetClock(clock)
+		s := NewScheduler(c)
+		// down since 21:30 the day before, missing 22:00, 23:00 and 00:00
+		err := s.Add(Job{
+			Name:    "flip",
+			Trigger: mustCron(t, "0 * * * *"),
+			Action:  SequenceStep{Action: ActionFlip, Channels: []Channel{1}},
+			Missed:  tt.policy,
+			LastRun: clock.Now().Add(-150 * time.Minute),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		clock.Advance(5 * time.Minute)
+		s.Start()
+		j, _ := s.Job("flip")
+		if j.Runs != tt.runs || j.Skipped != tt.skipped || board.get() != uint32(tt.runs%2) {
+			t.Fatalf("policy %d: job %+v board %#x", tt.policy, j, board.get())
+		}
+		if !j.Next.Equal(clock.Now().Add(55 * time.Minute)) {
+			t.Fatalf("policy %d: next %v", tt.policy, j.Next)
+		}
+		s.Stop()
+	}
+}
+
+func TestScheduler_MissedOnce(t *testing.T) {
+	for _, tt := range []struct {
+		policy        MissedPolicy
+		runs, skipped int
+	}{
+		{MissedSkip, 0, 1},
+		{MissedRunOnce, 1, 0},
+		{MissedRunAll, 1, 0},
+	} {
+		c, board := newTestClient(t, DefaultBranchesLength)
+		clock := newFakeClock()
+		c.SetClock(clock)
+		s := NewScheduler(c)
+		// due an hour ago while the process was down
+		if err := s.At("x", clock.Now().Add(-time.Hour), SequenceStep{Action: ActionOn, Channels: []Channel{1}}, tt.policy); err != nil {
+			t.Fatal(err)
+		}
+		s.Start()
+		j, _ := s.Job("x")
+		if j.Runs != tt.runs || j.Skipped != tt.skipped || board.get() != uint32(tt.runs) || !j.Next.IsZero() {
+			t.Fatalf("policy %d: job %+v board %#x", tt.policy, j, board.get())
+		}
+		err := s.At("y", clock.Now().Add(-time.Second), SequenceStep{Action: ActionOn, Channels: []Channel{2}}, tt.policy)
+		if err != ErrJobTime {
+			t.Fatalf("policy %d: err %v, want %v", tt.policy, err, ErrJobTime)
+		}
+		s.Stop()
+	}
+}
+
+func TestScheduler_Tolerance(t *testing.T) {
+	c, board := newTestClient(t, DefaultBranchesLength)
+	clock := newFakeClock()
+	c.SetClock(clock)
+	s := NewScheduler(c)
+	s.Start()
+	defer s.Stop()
+	if err := s.AddCron("on", "*/10 * * * *", time.UTC, SequenceStep{Action: ActionOn, Channels: []Channel{3}}, MissedSkip); err != nil {
+		t.Fatal(err)
+	}
+	// a timer firing a few seconds late still runs
+	s.mu.Lock()
+	s.jobs["on"].timer.Stop()
+	s.mu.Unlock()
+	clock.Advance(10*time.Minute + 30*time.Second)
+	s.fire(s.jobs["on"])
+	if j, _ := s.Job("on"); j.Runs != 1 || board.get() != 0x04 {
+		t.Fatalf("job %+v board %#x", j, board.get())
+	}
+}
+
+func mustCron(t *testing.T, spec string) *Cron {
+	t.Helper()
+	c, err := ParseCron(spec, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestScheduler_SavedLastRun(t *testing.T) {
+	store := NewFileStore(filepath.Join(t.TempDir(), "relay.json"))
+	clock := newFakeClock()
+	flip := SequenceStep{Action: ActionFlip, Channels: []Channel{1}}
+	c, _ := newTestClient(t, DefaultBranchesLength)
+	c.SetClock(clock)
+	c.SetStateStore(store)
+	s := NewScheduler(c)
+	if err := s.AddCron("flip", "0 * * * *", time.UTC, flip, MissedRunOnce); err != nil {
+		t.Fatal(err)
+	}
+	s.Start()
+	clock.Advance(time.Hour)
+	s.Stop()
+	if j, _ := s.Job("flip"); j.Runs != 1 {
+		t.Fatalf("job %+v", j)
+	}
+
+	// down for three runs, then restarted
+	clock.Advance(3*time.Hour + 30*time.Minute)
+	c, board := newTestClient(t, DefaultBranchesLength)
+	c.SetClock(clock)
+	c.SetStateStore(store)
+	if err := c.Restore(AdoptHardware); err != nil {
+		t.Fatal(err)
+	}
+	s = NewScheduler(c)
+	if err := s.AddCron("flip", "0 * * * *", time.UTC, flip, MissedRunOnce); err != nil {
+		t.Fatal(err)
+	}
+	s.Start()
+	defer s.Stop()
+	j, _ := s.Job("flip")
+	if j.Runs != 1 || j.Skipped != 2 || board.get() != 0x01 {
+		t.Fatalf("job %+v board %#x, want one catch-up run", j, board.get())
+	}
+}
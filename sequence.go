@@ -0,0 +1,251 @@
+package relay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrSequenceStep = errors.New("序列步骤无效")
+
+// Duration is a time.Duration written as a string like "500ms" in config
+// files. Plain numbers are nanoseconds.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		var n int64
+		if err := json.Unmarshal(data, &n); err != nil {
+			return err
+		}
+		*d = Duration(n)
+		return nil
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Sequence actions.
+const (
+	ActionOn       = "on"
+	ActionOff      = "off"
+	ActionFlip     = "flip"
+	ActionPulse    = "pulse"
+	ActionPulseOff = "pulse_off"
+	ActionScene    = "scene"
+	ActionWait     = "wait"
+	ActionLoop     = "loop"
+)
+
+// Sequence is a list of timed steps run on a client by RunSequence:
+//
+//  {"name": "rig", "board": "rig", "steps": [{"action": "loop", "repeat": 10, "steps": [
+//    {"action": "on", "channels": [1]},
+//    {"action": "wait", "duration": "500ms"},
+//    {"action": "pulse", "channels": [2], "duration": "200ms"},
+//    {"action": "flip", "channels": [3, 4]}
+//  ]}]}
+type Sequence struct {
+	Name string `json:"name"`
+	// Board is the board of a Config the sequence runs on.
+	Board string         `json:"board,omitempty"`
+	Steps []SequenceStep `json:"steps"`
+}
+
+// SequenceStep is a step of a Sequence. On, off and flip switch a single
+// channel with the one channel commands and several with a group command.
+// Pulse and pulse_off start a point command on a single channel without
+// waiting for its end. A loop runs its steps Repeat times.
+type SequenceStep struct {
+	Action   string         `json:"action"`
+	Channels []Channel      `json:"channels,omitempty"`
+	Duration Duration       `json:"duration,omitempty"`
+	Scene    string         `json:"scene,omitempty"`
+	Repeat   int            `json:"repeat,omitempty"`
+	Steps    []SequenceStep `json:"steps,omitempty"`
+}
+
+func (s SequenceStep) String() string {
+	switch s.Action {
+	case ActionWait:
+		return fmt.Sprintf("wait %v", time.Duration(s.Duration))
+	case ActionPulse, ActionPulseOff:
+		return fmt.Sprintf("%s %v %v", s.Action, s.Channels, time.Duration(s.Duration))
+	case ActionScene:
+		return fmt.Sprintf("scene %s", s.Scene)
+	case ActionLoop:
+		return fmt.Sprintf("loop %d", s.Repeat)
+	}
+	return fmt.Sprintf("%s %v", s.Action, s.Channels)
+}
+
+// Validate checks the steps of the sequence. Channels are checked against
+// the client when the sequence runs.
+func (seq *Sequence) Validate() error {
+	return validateSteps(seq.Steps)
+}
+
+func validateSteps(steps []SequenceStep) error {
+	for _, s := range steps {
+		ok := false
+		switch s.Action {
+		case ActionOn, ActionOff, ActionFlip:
+			ok = len(s.Channels) > 0
+		case ActionPulse, ActionPulseOff:
+			ok = len(s.Channels) == 1 && s.Duration > 0
+		case ActionScene:
+			ok = s.Scene != ""
+		case ActionWait:
+			ok = s.Duration > 0
+		case ActionLoop:
+			ok = s.Repeat > 0 && len(s.Steps) > 0
+		}
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrSequenceStep, s)
+		}
+		if err := validateSteps(s.Steps); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SequenceProgress is reported after every executed step.
+type SequenceProgress struct {
+	Sequence string
+	// Step is the executed step, Count the number of steps executed so far
+	// including this one. Loops themselves are not counted.
+	Step  SequenceStep
+	Count int
+	Err   error
+}
+
+// SequenceError is returned by RunSequence when a step fails.
+type SequenceError struct {
+	Sequence string
+	Step     SequenceStep
+	Count    int
+	Err      error
+}
+
+func (e *SequenceError) Error() string {
+	return fmt.Sprintf("序列 %s 第%d步 %s 失败: %v", e.Sequence, e.Count, e.Step, e.Err)
+}
+
+func (e *SequenceError) Unwrap() error {
+	return e.Err
+}
+
+// RunSequence runs the steps of the sequence in order and calls progress,
+// if not nil, after every step. It stops at the first failing step with a
+// *SequenceError. The context is checked before every step and interrupts
+// waits, a canceled sequence returns the error of the context. Channels are
+// left as they are when the sequence stops.
+func (c *Client) RunSequence(ctx context.Context, seq Sequence, progress func(SequenceProgress)) error {
+	if err := seq.Validate(); err != nil {
+		return err
+	}
+	r := &sequenceRun{client: c, seq: seq.Name, progress: progress}
+	return r.run(ctx, seq.Steps)
+}
+
+type sequenceRun struct {
+	client   *Client
+	seq      string
+	progress func(SequenceProgress)
+	count    int
+}
+
+func (r *sequenceRun) run(ctx context.Context, steps []SequenceStep) error {
+	for _, s := range steps {
+		if s.Action == ActionLoop {
+			for i := 0; i < s.Repeat; i++ {
+				if err := r.run(ctx, s.Steps); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		err := r.step(ctx, s)
+		if err != nil && err == ctx.Err() {
+			return err
+		}
+		r.count++
+		if r.progress != nil {
+			r.progress(SequenceProgress{Sequence: r.seq, Step: s, Count: r.count, Err: err})
+		}
+		if err != nil {
+			return &SequenceError{Sequence: r.seq, Step: s, Count: r.count, Err: err}
+		}
+	}
+	return nil
+}
+
+func (r *sequenceRun) step(ctx context.Context, s SequenceStep) error {
+	c := r.client
+	one := len(s.Channels) == 1
+	switch s.Action {
+	case ActionOn:
+		if one {
+			return c.On(s.Channels[0])
+		}
+		return c.OnChannels(s.Channels...)
+	case ActionOff:
+		if one {
+			return c.Off(s.Channels[0])
+		}
+		return c.OffChannels(s.Channels...)
+	case ActionFlip:
+		if one {
+			return c.Flip(s.Channels[0])
+		}
+		return c.FlipChannels(s.Channels...)
+	case ActionPulse:
+		_, err := c.Pulse(s.Channels[0], time.Duration(s.Duration))
+		return err
+	case ActionPulseOff:
+		_, err := c.PulseOff(s.Channels[0], time.Duration(s.Duration))
+		return err
+	case ActionScene:
+		return c.ActivateScene(s.Scene)
+	case ActionWait:
+		return c.wait(ctx, time.Duration(s.Duration))
+	}
+	return ErrSequenceStep
+}
+
+// wait sleeps for d on the client clock unless the context is done first.
+func (c *Client) wait(ctx context.Context, d time.Duration) error {
+	c.Lock()
+	clock := c.clock
+	c.Unlock()
+	if ctx.Done() == nil {
+		clock.Sleep(d)
+		return nil
+	}
+	fired := make(chan struct{})
+	t := clock.AfterFunc(d, func() {
+		close(fired)
+	})
+	select {
+	case <-fired:
+		return nil
+	case <-ctx.Done():
+		t.Stop()
+		return ctx.Err()
+	}
+}
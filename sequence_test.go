@@ -0,0 +1,125 @@
+package relay
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClient_RunSequence(t *testing.T) {
+	c, board := newTestClient(t, DefaultBranchesLength)
+	clock := newFakeClock()
+	c.SetClock(clock)
+	start := clock.Now()
+	seq := Sequence{Name: "rig", Steps: []SequenceStep{
+		{Action: ActionLoop, Repeat: 10, Steps: []SequenceStep{
+			{Action: ActionOn, Channels: []Channel{1}},
+			{Action: ActionWait, Duration: Duration(500 * time.Millisecond)},
+			{Action: ActionPulse, Channels: []Channel{2}, Duration: Duration(200 * time.Millisecond)},
+			{Action: ActionFlip, Channels: []Channel{3, 4}},
+		}},
+		{Action: ActionOff, Channels: []Channel{1}},
+	}}
+	var progress []SequenceProgress
+	err := c.RunSequence(context.Background(), seq, func(p SequenceProgress) {
+		progress = append(progress, p)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(progress) != 41 || progress[40].Count != 41 || progress[1].Step.String() != "wait 500ms" {
+		t.Fatalf("%d progress reports, last %+v", len(progress), progress[len(progress)-1])
+	}
+	// flipped an even number of times, the last pulse is still running
+	if board.get() != 0x02 || clock.Now().Sub(start) != 5*time.Second {
+		t.Fatalf("board %#x elapsed %v", board.get(), clock.Now().Sub(start))
+	}
+}
+
+func TestClient_RunSequenceFailure(t *testing.T) {
+	c, _ := newTestClient(t, DefaultBranchesLength)
+	seq := Sequence{Name: "bad", Steps: []SequenceStep{
+		{Action: ActionOn, Channels: []Channel{1}},
+		{Action: ActionOn, Channels: []Channel{9}},
+		{Action: ActionOff, Channels: []Channel{1}},
+	}}
+	var last SequenceProgress
+	err := c.RunSequence(context.Background(), seq, func(p SequenceProgress) {
+		last = p
+	})
+	var e *SequenceError
+	if !errors.As(err, &e) || e.Count != 2 || !errors.Is(err, ErrBranchesLength) {
+		t.Fatalf("err %v", err)
+	}
+	if last.Count != 2 || last.Err != ErrBranchesLength {
+		t.Fatalf("progress %+v", last)
+	}
+	if err := c.RunSequence(context.Background(), Sequence{Steps: []SequenceStep{{Action: ActionWait}}}, nil); !errors.Is(err, ErrSequenceStep) {
+		t.Fatalf("err %v, want %v", err, ErrSequenceStep)
+	}
+}
+
+func TestClient_RunSequenceCanceled(t *testing.T) {
+	c, board := newTestClient(t, DefaultBranchesLength)
+	c.SetClock(newFakeClock())
+	ctx, cancel := context.WithCancel(context.Background())
+	seq := Sequence{Steps: []SequenceStep{
+		{Action: ActionOn, Channels: []Channel{1}},
+		{Action: ActionWait, Duration: Duration(time.Hour)},
+		{Action: ActionOn, Channels: []Channel{2}},
+	}}
+	count := 0
+	err := c.RunSequence(ctx, seq, func(p SequenceProgress) {
+		count = p.Count
+		cancel()
+	})
+	if err != context.Canceled || count != 1 || board.get() != 0x01 {
+		t.Fatalf("err %v after %d steps, board %#x", err, count, board.get())
+	}
+}
+
+func TestRegistry_RunSequence(t *testing.T) {
+	cfg, err := ReadConfig(strings.NewReader(`{
+  "boards": [{"name": "rig", "port": "/dev/ttyUSB0", "slave_id": 1}],
+  "sequences": [{"name": "blink", "board": "rig", "steps": [
+    {"action": "loop", "repeat": 2, "steps": [
+      {"action": "flip", "channels": [1, 2]},
+      {"action": "wait", "duration": "500ms"}
+    ]}
+  ]}]
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewRegistry(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	board := &fakeBoard{}
+	rig := r.Board("rig")
+	rig.transporter = board
+	clock := newFakeClock()
+	rig.SetClock(clock)
+	start := clock.Now()
+	if err := r.RunSequence(context.Background(), "blink", nil); err != nil {
+		t.Fatal(err)
+	}
+	if clock.Now().Sub(start) != time.Second || len(board.frames) != 2 {
+		t.Fatalf("elapsed %v frames %v", clock.Now().Sub(start), board.frames)
+	}
+	if err := r.RunSequence(context.Background(), "fade", nil); err != ErrUnknownSequence {
+		t.Fatalf("err %v, want %v", err, ErrUnknownSequence)
+	}
+
+	for _, s := range []string{
+		`{"boards": [{"name": "a", "port": "p"}], "sequences": [{"name": "x", "board": "b", "steps": []}]}`,
+		`{"boards": [{"name": "a", "port": "p"}], "sequences": [{"name": "x", "board": "a", "steps": [{"action": "dance"}]}]}`,
+		`{"boards": [{"name": "a", "port": "p"}], "sequences": [{"name": "x", "board": "a", "steps": [{"action": "wait", "duration": "soon"}]}]}`,
+	} {
+		if _, err := ReadConfig(strings.NewReader(s)); err == nil {
+			t.Fatalf("config %s accepted", s)
+		}
+	}
+}
@@ -21,12 +21,14 @@ type serialPort struct {
 
 	Logger      *log.Logger
 	IdleTimeout time.Duration
+	// Clock is used for the idle timer and frame delays, SystemClock if nil.
+	Clock Clock
 
 	mu sync.Mutex
 	// port is platform-dependent data structure for serial port.
 	port         io.ReadWriteCloser
 	lastActivity time.Time
-	closeTimer   *time.Timer
+	closeTimer   Timer
 }
 
 func (mb *serialPort) Connect() (err error) {
@@ -57,6 +59,9 @@ func (mb *serialPort) Close() (err error) {
 
 // close closes the serial port if it is connected. Caller must hold the mutex.
 func (mb *serialPort) close() (err error) {
+	if mb.closeTimer != nil {
+		mb.closeTimer.Stop()
+	}
 	if mb.port != nil {
 		err = mb.port.Close()
 		mb.port = nil
@@ -70,12 +75,19 @@ func (mb *serialPort) logf(format string, v ...interface{}) {
 	}
 }
 
+func (mb *serialPort) clock() Clock {
+	if mb.Clock == nil {
+		return SystemClock
+	}
+	return mb.Clock
+}
+
 func (mb *serialPort) startCloseTimer() {
 	if mb.IdleTimeout <= 0 {
 		return
 	}
 	if mb.closeTimer == nil {
-		mb.closeTimer = time.AfterFunc(mb.IdleTimeout, mb.closeIdle)
+		mb.closeTimer = mb.clock().AfterFunc(mb.IdleTimeout, mb.closeIdle)
 	} else {
 		mb.closeTimer.Reset(mb.IdleTimeout)
 	}
@@ -89,7 +101,7 @@ func (mb *serialPort) closeIdle() {
 	if mb.IdleTimeout <= 0 {
 		return
 	}
-	idle := time.Now().Sub(mb.lastActivity)
+	idle := mb.clock().Now().Sub(mb.lastActivity)
 	if idle >= mb.IdleTimeout {
 		mb.logf("modbus: closing connection due to idle timeout: %v", idle)
 		_ = mb.close()
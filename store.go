@@ -0,0 +1,404 @@
+package relay
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+var ErrStateStore = errors.New("未设置状态存储")
+
+// State is the commanded state of a client kept by a StateStore.
+type State struct {
+	// Commanded is the last commanded state, BIT0 is channel 1.
+	Commanded uint32 `json:"commanded"`
+	// Pulses are the pulses running when the state was saved.
+	Pulses []PulseState `json:"pulses,omitempty"`
+	// Channels holds the metadata of the channels.
+	Channels map[Channel]ChannelMeta `json:"channels,omitempty"`
+	// MaxOn holds the maximum on-time of the limited channels.
+	MaxOn map[Channel]time.Duration `json:"max_on,omitempty"`
+	// OnSince holds when the channels that are on were switched on.
+	OnSince map[Channel]time.Time `json:"on_since,omitempty"`
+	// Wear holds the switching counters of the channels.
+	Wear map[Channel]WearState `json:"wear,omitempty"`
+	// Latch is the emergency stop that latched the client, nil if it is
+	// not latched.
+	Latch *LatchRecord `json:"latch,omitempty"`
+	// Lockouts are the channels pinned off for maintenance.
+	Lockouts []Lockout `json:"lockouts,omitempty"`
+	// Scenes are the saved scenes sorted by name.
+	Scenes []Scene `json:"scenes,omitempty"`
+	// Jobs holds the last run of the scheduled jobs by name.
+	Jobs map[string]time.Time `json:"jobs,omitempty"`
+}
+
+// WearState is the saved switching counters of a channel.
+type WearState struct {
+	Cycles uint64        `json:"cycles"`
+	OnTime time.Duration `json:"on_time"`
+}
+
+// PulseState is a running pulse in a saved State.
+type PulseState struct {
+	Channel Channel `json:"channel"`
+	// Off is set for pulses that open the channel, see Client.PulseOff.
+	Off bool      `json:"off,omitempty"`
+	End time.Time `json:"end"`
+}
+
+// ChannelMeta describes what is connected to a channel.
+type ChannelMeta struct {
+	Label      string            `json:"label,omitempty"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// StateStore persists the state of a client across restarts.
+type StateStore interface {
+	// Load returns the saved state, nil if nothing was saved yet.
+	Load() (*State, error)
+	Save(state *State) error
+}
+
+// FileStore is a StateStore keeping the state as JSON in a file.
+type FileStore struct {
+	Path string
+}
+
+// NewFileStore returns a FileStore saving to path.
+func NewFileStore(path string) *FileStore {
+	return &FileStore{Path: path}
+}
+
+func (s *FileStore) Load() (*State, error) {
+	data, err := ioutil.ReadFile(s.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	state := &State{}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// Save writes the state to a temporary file and renames it over Path, so
+// a crash never leaves a partially written state behind.
+func (s *FileStore) Save(state *State) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(s.Path), filepath.Base(s.Path)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(data); err == nil {
+		err = tmp.Sync()
+	}
+	if e := tmp.Close(); err == nil {
+		err = e
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), s.Path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// RestorePolicy decides how Client.Restore reconciles the saved state with
+// the board.
+type RestorePolicy int
+
+const (
+	// RestoreCommanded drives the board back to the saved state and restarts
+	// the pulses that have not ended yet.
+	RestoreCommanded RestorePolicy = iota
+	// AdoptHardware keeps the board as it is and takes its state as the
+	// commanded one. Saved pulses are dropped.
+	AdoptHardware
+)
+
+// SetStateStore sets the store the client saves its state to after every
+// change. Call Restore afterwards to load the saved state.
+func (c *Client) SetStateStore(store StateStore) {
+	c.Lock()
+	defer c.Unlock()
+	c.store = store
+	c.saved = nil
+}
+
+// Restore loads the saved state and reconciles it with the board according
+// to policy. Without a saved state the board state is adopted. Saved
+// metadata, limits, counters, lockouts and scenes are merged into the ones
+// already configured, which are kept if nothing was saved. A saved
+// emergency stop latches the client again and RestoreCommanded then switches
+// all channels off instead.
+func (c *Client) Restore(policy RestorePolicy) error {
+	c.Lock()
+	store := c.store
+	c.Unlock()
+	if store == nil {
+		return ErrStateStore
+	}
+	state, err := store.Load()
+	if err != nil {
+		return err
+	}
+	if state == nil {
+		policy = AdoptHardware
+		state = &State{}
+	}
+	// merge what was saved, configuration set before Restore is kept
+	c.Lock()
+	for ch, meta := range state.Channels {
+		if c.meta == nil {
+			c.meta = make(map[Channel]ChannelMeta)
+		}
+		c.meta[ch] = meta
+	}
+	for ch := Channel(1); byte(ch) <= c.length; ch++ {
+		if d, ok := state.MaxOn[ch]; ok {
+			c.maxOn[ch-1] = d
+		}
+		if w, ok := state.Wear[ch]; ok {
+			c.cycles[ch-1] = w.Cycles
+			c.onTime[ch-1] = w.OnTime
+		}
+	}
+	if state.Latch != nil {
+		atomic.StoreInt32(&c.latched, 1)
+		c.audit = append(c.audit, *state.Latch)
+	}
+	for _, l := range state.Lockouts {
+		if c.lockouts == nil {
+			c.lockouts = make(map[Channel]Lockout)
+		}
+		c.lockouts[l.Channel] = l
+	}
+	for _, s := range state.Scenes {
+		if c.scenes == nil {
+			c.scenes = make(map[string]Scene)
+		}
+		c.scenes[s.Name] = s
+	}
+	for name, t := range state.Jobs {
+		if c.jobs == nil {
+			c.jobs = make(map[string]time.Time)
+		}
+		c.jobs[name] = t
+	}
+	var locked uint32
+	for ch := range c.lockouts {
+		locked |= ch.bit()
+	}
+	c.Unlock()
+	defer c.restoreOnSince(state.OnSince)
+	if policy == AdoptHardware {
+		return c.adopt()
+	}
+
+	if state.Latch != nil {
+		return c.SetAll(0)
+	}
+	mask := state.Commanded
+	for _, p := range state.Pulses {
+		if p.Off {
+			mask |= p.Channel.bit()
+		} else {
+			mask &^= p.Channel.bit()
+		}
+	}
+	if err := c.restoreAll(mask &^ locked & c.full()); err != nil {
+		return err
+	}
+	now := c.clock.Now()
+	for _, p := range state.Pulses {
+		d := p.End.Sub(now)
+		if d < time.Millisecond || locked&p.Channel.bit() != 0 {
+			continue
+		}
+		code := byte(RequestOnPoint)
+		if p.Off {
+			code = RequestOffPoint
+		}
+		if _, err := c.pulse(code, p.Channel, d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restoreAll switches the board to the commanded state like SetAll. The
+// channels it switches on were on before the restart and are not counted as
+// switching cycles again.
+func (c *Client) restoreAll(mask uint32) error {
+	data := maskData(mask)
+	c.acquire(RequestRunCMD, data)
+	defer c.unlock()
+	c.restoring = true
+	defer func() {
+		c.restoring = false
+	}()
+	status, err := c.commandLocked(RequestRunCMD, data)
+	if err != nil {
+		return err
+	}
+	if status&c.full() != mask {
+		return ErrReturnResult
+	}
+	return nil
+}
+
+// restoreOnSince keeps the saved on-time of channels that are still on.
+// A channel found off drops the running on-time discounted by ResetWear.
+func (c *Client) restoreOnSince(since map[Channel]time.Time) {
+	c.Lock()
+	defer c.unlock()
+	for ch, t := range since {
+		if c.check(ch) != nil {
+			continue
+		}
+		if c.stat[ch-1] == 1 {
+			c.onAt[ch-1] = t
+			c.armMaxOn(ch)
+		} else if c.onTime[ch-1] < 0 {
+			c.onTime[ch-1] = 0
+		}
+	}
+}
+
+// adopt reads the board and replaces the cached state with it. Channels
+// found on are not counted as switching cycles.
+func (c *Client) adopt() error {
+	c.Lock()
+	defer c.unlock()
+	c.restoring = true
+	defer func() {
+		c.restoring = false
+	}()
+	status, err := c.readStatusLocked()
+	if err != nil {
+		return err
+	}
+	c.setStat(status)
+	return nil
+}
+
+// SetChannelMeta sets the metadata of the channel, it is saved with the state.
+func (c *Client) SetChannelMeta(ch Channel, meta ChannelMeta) error {
+	if err := c.check(ch); err != nil {
+		return err
+	}
+	c.Lock()
+	defer c.unlock()
+	if c.meta == nil {
+		c.meta = make(map[Channel]ChannelMeta)
+	}
+	c.meta[ch] = meta
+	return nil
+}
+
+// ChannelMeta returns the metadata of the channel.
+func (c *Client) ChannelMeta(ch Channel) ChannelMeta {
+	c.Lock()
+	defer c.Unlock()
+	return c.meta[ch]
+}
+
+// Snapshot returns the current commanded state.
+func (c *Client) Snapshot() *State {
+	c.Lock()
+	defer c.Unlock()
+	return c.snapshot()
+}
+
+// snapshot builds the state to save. Caller must hold the lock.
+func (c *Client) snapshot() *State {
+	state := &State{Commanded: c.cached()}
+	for ch := Channel(1); byte(ch) <= c.length; ch++ {
+		if p, ok := c.pulses[ch]; ok {
+			state.Pulses = append(state.Pulses, PulseState{
+				Channel: ch,
+				Off:     p.level() == 1,
+				End:     p.end,
+			})
+		}
+	}
+	for ch := Channel(1); byte(ch) <= c.length; ch++ {
+		if d := c.maxOn[ch-1]; d > 0 {
+			if state.MaxOn == nil {
+				state.MaxOn = make(map[Channel]time.Duration)
+			}
+			state.MaxOn[ch] = d
+		}
+		// a negative on-time discounts the running on-time after ResetWear
+		if c.cycles[ch-1] > 0 || c.onTime[ch-1] != 0 {
+			if state.Wear == nil {
+				state.Wear = make(map[Channel]WearState)
+			}
+			state.Wear[ch] = WearState{Cycles: c.cycles[ch-1], OnTime: c.onTime[ch-1]}
+		}
+		if c.stat[ch-1] == 1 {
+			if state.OnSince == nil {
+				state.OnSince = make(map[Channel]time.Time)
+			}
+			state.OnSince[ch] = c.onAt[ch-1]
+		}
+	}
+	if c.Latched() {
+		for k := len(c.audit) - 1; k >= 0; k-- {
+			if c.audit[k].Latched {
+				latch := c.audit[k]
+				state.Latch = &latch
+				break
+			}
+		}
+	}
+	for ch := Channel(1); byte(ch) <= c.length; ch++ {
+		if l, ok := c.lockouts[ch]; ok {
+			state.Lockouts = append(state.Lockouts, l)
+		}
+	}
+	state.Scenes = c.sortedScenes()
+	if len(c.jobs) > 0 {
+		state.Jobs = make(map[string]time.Time, len(c.jobs))
+		for name, t := range c.jobs {
+			state.Jobs[name] = t
+		}
+	}
+	if len(c.meta) > 0 {
+		state.Channels = make(map[Channel]ChannelMeta, len(c.meta))
+		for ch, meta := range c.meta {
+			state.Channels[ch] = meta
+		}
+	}
+	return state
+}
+
+// persist saves the state if it changed since the last save. Caller must
+// hold the lock.
+func (c *Client) persist() {
+	if c.store == nil {
+		return
+	}
+	state := c.snapshot()
+	data, err := json.Marshal(state)
+	if err != nil || string(data) == string(c.saved) {
+		return
+	}
+	if err := c.store.Save(state); err != nil {
+		c.emit(EventStateSaveFailed, 0, err)
+		return
+	}
+	c.saved = data
+}
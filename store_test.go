@@ -0,0 +1,133 @@
+package relay
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileStore(t *testing.T) {
+	store := NewFileStore(filepath.Join(t.TempDir(), "relay.json"))
+	state, err := store.Load()
+	if err != nil || state != nil {
+		t.Fatalf("state %v err %v, want nothing saved", state, err)
+	}
+	want := &State{
+		Commanded: 0x05,
+		Pulses:    []PulseState{{Channel: 2, End: time.Unix(1622505600, 0)}},
+		Channels:  map[Channel]ChannelMeta{2: {Label: "pump"}},
+	}
+	if err := store.Save(want); err != nil {
+		t.Fatal(err)
+	}
+	state, err = store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Commanded != want.Commanded || len(state.Pulses) != 1 ||
+		!state.Pulses[0].End.Equal(want.Pulses[0].End) || state.Channels[2].Label != "pump" {
+		t.Fatalf("state %+v, want %+v", state, want)
+	}
+}
+
+func TestClient_Restore(t *testing.T) {
+	store := NewFileStore(filepath.Join(t.TempDir(), "relay.json"))
+	clock := newFakeClock()
+	c, _ := newTestClient(t, DefaultBranchesLength)
+	c.SetClock(clock)
+	c.SetStateStore(store)
+	if err := c.OnChannels(1, 3); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Pulse(5, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetChannelMeta(1, ChannelMeta{Label: "heater"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// restart with a board that lost its state
+	clock.Advance(20 * time.Second)
+	c, board := newTestClient(t, DefaultBranchesLength)
+	c.SetClock(clock)
+	c.SetStateStore(store)
+	if err := c.Restore(RestoreCommanded); err != nil {
+		t.Fatal(err)
+	}
+	if board.get() != 0x15 {
+		t.Fatalf("board %#x, want %#x", board.get(), 0x15)
+	}
+	p := c.ActivePulse(5)
+	if p == nil || p.Remaining() != 40*time.Second {
+		t.Fatalf("pulse %v not restored with remaining time", p)
+	}
+	if c.ChannelMeta(1).Label != "heater" {
+		t.Fatalf("meta %+v not restored", c.ChannelMeta(1))
+	}
+
+	// restart and keep whatever the board does now
+	c, board = newTestClient(t, DefaultBranchesLength)
+	board.state = 0x80
+	c.SetClock(clock)
+	c.SetStateStore(store)
+	if err := c.Restore(AdoptHardware); err != nil {
+		t.Fatal(err)
+	}
+	if c.GetStats()[7] != 1 || c.ActivePulse(5) != nil {
+		t.Fatalf("hardware state not adopted: %v", c.GetStats())
+	}
+	state, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Commanded != 0x80 || len(state.Pulses) != 0 {
+		t.Fatalf("saved state %+v, want adopted hardware state", state)
+	}
+}
+
+func TestClient_RestoreKeepsConfiguration(t *testing.T) {
+	c, _ := newTestClient(t, DefaultBranchesLength)
+	if err := c.SetMaxOn(1, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetChannelMeta(2, ChannelMeta{Label: "pump"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Lockout(3, "alice", "service"); err != nil {
+		t.Fatal(err)
+	}
+	// first run, nothing saved yet
+	store := NewFileStore(filepath.Join(t.TempDir(), "relay.json"))
+	c.SetStateStore(store)
+	if err := c.Restore(AdoptHardware); err != nil {
+		t.Fatal(err)
+	}
+	if c.MaxOn(1) != time.Minute || c.ChannelMeta(2).Label != "pump" || len(c.Lockouts()) != 1 {
+		t.Fatalf("configuration lost: max on %v meta %+v lockouts %v", c.MaxOn(1), c.ChannelMeta(2), c.Lockouts())
+	}
+
+	// saved entries are merged into the configured ones
+	if err := store.Save(&State{Channels: map[Channel]ChannelMeta{4: {Label: "fan"}}}); err != nil {
+		t.Fatal(err)
+	}
+	c, _ = newTestClient(t, DefaultBranchesLength)
+	if err := c.SetMaxOn(1, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetChannelMeta(2, ChannelMeta{Label: "pump"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Lockout(3, "alice", "service"); err != nil {
+		t.Fatal(err)
+	}
+	c.SetStateStore(store)
+	if err := c.Restore(RestoreCommanded); err != nil {
+		t.Fatal(err)
+	}
+	if c.MaxOn(1) != time.Minute || c.ChannelMeta(2).Label != "pump" || c.ChannelMeta(4).Label != "fan" {
+		t.Fatalf("max on %v meta %+v %+v", c.MaxOn(1), c.ChannelMeta(2), c.ChannelMeta(4))
+	}
+	if len(c.Lockouts()) != 1 {
+		t.Fatalf("lockouts %v", c.Lockouts())
+	}
+}
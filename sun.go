@@ -0,0 +1,110 @@
+package relay
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// SunEvent selects sunrise or sunset for a Sun trigger.
+type SunEvent int
+
+const (
+	Sunrise SunEvent = iota
+	Sunset
+)
+
+func (e SunEvent) String() string {
+	if e == Sunset {
+		return "sunset"
+	}
+	return "sunrise"
+}
+
+// Sun is a Trigger firing daily at sunrise or sunset, shifted by Offset.
+// The times are computed offline from the coordinates with the sunrise
+// equation and are accurate to about a minute away from the polar circles.
+// Days without the event, such as the polar day, are skipped.
+type Sun struct {
+	Event SunEvent
+	// Latitude is north positive, Longitude east positive, in degrees.
+	Latitude  float64
+	Longitude float64
+	// Offset is added to the event, e.g. -30 * time.Minute for half an
+	// hour before sunset.
+	Offset time.Duration
+	// Location decides which calendar day an event belongs to, UTC if nil.
+	// It should be the zone of the coordinates.
+	Location *time.Location
+}
+
+// sunSearchDays bounds the search for the next event, long enough to get
+// through a polar night.
+const sunSearchDays = 366
+
+func (s Sun) Next(after time.Time) time.Time {
+	loc := s.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	t := after.In(loc)
+	// start a day early, the offset may move an event across midnight
+	day := time.Date(t.Year(), t.Month(), t.Day()-1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < sunSearchDays; i++ {
+		rise, set, ok := SunTimes(day.AddDate(0, 0, i), s.Latitude, s.Longitude)
+		if !ok {
+			continue
+		}
+		event := rise
+		if s.Event == Sunset {
+			event = set
+		}
+		if event = event.Add(s.Offset); event.After(after) {
+			return event.In(loc)
+		}
+	}
+	return time.Time{}
+}
+
+func (s Sun) String() string {
+	return fmt.Sprintf("%s %+.4f,%+.4f offset %v", s.Event, s.Latitude, s.Longitude, s.Offset)
+}
+
+// SunTimes returns sunrise and sunset on the calendar day of date at the
+// coordinates, in UTC. ok is false if the sun does not rise or does not set
+// that day.
+func SunTimes(date time.Time, latitude, longitude float64) (rise, set time.Time, ok bool) {
+	const (
+		j2000 = 2451545.0
+		rad   = math.Pi / 180
+	)
+	noon := time.Date(date.Year(), date.Month(), date.Day(), 12, 0, 0, 0, time.UTC)
+	// days since J2000 at noon, then the mean solar noon at the longitude
+	n := math.Round(julian(noon) - j2000 + 0.0008)
+	mean := n - longitude/360
+	// solar mean anomaly, equation of the center and ecliptic longitude
+	m := math.Mod(357.5291+0.98560028*mean, 360)
+	c := 1.9148*math.Sin(m*rad) + 0.02*math.Sin(2*m*rad) + 0.0003*math.Sin(3*m*rad)
+	lambda := math.Mod(m+c+180+102.9372, 360)
+	transit := j2000 + mean + 0.0053*math.Sin(m*rad) - 0.0069*math.Sin(2*lambda*rad)
+	// declination and hour angle of the sun at -0.833°, allowing for
+	// refraction and the solar disc
+	sinDecl := math.Sin(lambda*rad) * math.Sin(23.4397*rad)
+	cosDecl := math.Cos(math.Asin(sinDecl))
+	cosOmega := (math.Sin(-0.833*rad) - math.Sin(latitude*rad)*sinDecl) / (math.Cos(latitude*rad) * cosDecl)
+	if cosOmega < -1 || cosOmega > 1 {
+		return time.Time{}, time.Time{}, false
+	}
+	omega := math.Acos(cosOmega) / rad
+	return fromJulian(transit - omega/360), fromJulian(transit + omega/360), true
+}
+
+// julian returns the Julian date of the time.
+func julian(t time.Time) float64 {
+	return float64(t.Unix())/86400 + 2440587.5
+}
+
+// fromJulian returns the time of a Julian date, rounded to the second.
+func fromJulian(j float64) time.Time {
+	return time.Unix(int64(math.Round((j-2440587.5)*86400)), 0).UTC()
+}
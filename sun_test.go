@@ -0,0 +1,118 @@
+package relay
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSunTimes(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		lat, lon  float64
+		zone      string
+		date      time.Time
+		rise, set string
+	}{
+		{"london", 51.5074, -0.1278, "Europe/London", time.Date(2021, 6, 21, 0, 0, 0, 0, time.UTC), "04:43", "21:21"},
+		{"london", 51.5074, -0.1278, "Europe/London", time.Date(2021, 12, 21, 0, 0, 0, 0, time.UTC), "08:04", "15:54"},
+		{"shanghai", 31.2304, 121.4737, "Asia/Shanghai", time.Date(2021, 6, 21, 0, 0, 0, 0, time.UTC), "04:50", "19:01"},
+		{"sydney", -33.8688, 151.2093, "Australia/Sydney", time.Date(2021, 6, 21, 0, 0, 0, 0, time.UTC), "07:00", "16:54"},
+		{"new york", 40.7128, -74.0060, "America/New_York", time.Date(2021, 12, 21, 0, 0, 0, 0, time.UTC), "07:17", "16:32"},
+		{"quito", -0.1807, -78.4678, "America/Guayaquil", time.Date(2021, 3, 20, 0, 0, 0, 0, time.UTC), "06:18", "18:24"},
+	} {
+		loc, err := time.LoadLocation(tt.zone)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rise, set, ok := SunTimes(tt.date, tt.lat, tt.lon)
+		if !ok {
+			t.Fatalf("%s %s: no sunrise", tt.name, tt.date.Format("2006-01-02"))
+		}
+		for _, e := range []struct {
+			got  time.Time
+			want string
+		}{{rise, tt.rise}, {set, tt.set}} {
+			want, _ := time.ParseInLocation("2006-01-02 15:04", tt.date.Format("2006-01-02 ")+e.want, loc)
+			if !near(e.got, want) {
+				t.Errorf("%s %s: %v, want %s", tt.name, tt.date.Format("2006-01-02"), e.got.In(loc), e.want)
+			}
+		}
+	}
+
+	// midnight sun in Tromsø
+	if _, _, ok := SunTimes(time.Date(2021, 6, 21, 0, 0, 0, 0, time.UTC), 69.6492, 18.9553); ok {
+		t.Fatal("sun sets at midsummer in Tromsø")
+	}
+}
+
+func TestSun_Next(t *testing.T) {
+	shanghai, _ := time.LoadLocation("Asia/Shanghai")
+	dusk := Sun{Event: Sunset, Latitude: 31.2304, Longitude: 121.4737, Offset: -30 * time.Minute, Location: shanghai}
+	from := time.Date(2021, 6, 21, 12, 0, 0, 0, shanghai)
+	next := dusk.Next(from)
+	if !near(next, time.Date(2021, 6, 21, 18, 31, 0, 0, shanghai)) {
+		t.Fatalf("next %v", next)
+	}
+	if again := dusk.Next(next); again.Format("2006-01-02") != "2021-06-22" {
+		t.Fatalf("following %v", again)
+	}
+	// an offset moving sunrise to the previous evening
+	early := Sun{Event: Sunrise, Latitude: 31.2304, Longitude: 121.4737, Offset: -10 * time.Hour, Location: shanghai}
+	if next := early.Next(from); !near(next, time.Date(2021, 6, 21, 18, 50, 0, 0, shanghai)) {
+		t.Fatalf("next %v", next)
+	}
+
+	// the first sunset in Tromsø after the midnight sun
+	oslo, _ := time.LoadLocation("Europe/Oslo")
+	tromso := Sun{Event: Sunset, Latitude: 69.6492, Longitude: 18.9553, Location: oslo}
+	if next := tromso.Next(time.Date(2021, 6, 1, 0, 0, 0, 0, oslo)); next.Month() != time.July || next.Day() < 20 {
+		t.Fatalf("next %v, want late July", next)
+	}
+}
+
+func TestScheduler_Sun(t *testing.T) {
+	c, board := newTestClient(t, DefaultBranchesLength)
+	clock := newFakeClock() // 2021-06-01 00:00 UTC
+	c.SetClock(clock)
+	london, _ := time.LoadLocation("Europe/London")
+	s := NewScheduler(c)
+	lights := []Channel{1, 2}
+	sun := Sun{Latitude: 51.5074, Longitude: -0.1278, Location: london}
+	sun.Event = Sunset
+	if err := s.Add(Job{Name: "lights.on", Trigger: sun, Action: SequenceStep{Action: ActionOn, Channels: lights}}); err != nil {
+		t.Fatal(err)
+	}
+	sun.Event, sun.Offset = Sunrise, 15*time.Minute
+	if err := s.Add(Job{Name: "lights.off", Trigger: sun, Action: SequenceStep{Action: ActionOff, Channels: lights}}); err != nil {
+		t.Fatal(err)
+	}
+	s.Start()
+	defer s.Stop()
+
+	// lights.off also runs at sunrise on the first day
+	on, _ := s.Job("lights.on")
+	if !near(on.Next, time.Date(2021, 6, 1, 21, 8, 0, 0, london)) {
+		t.Fatalf("lights on at %v", on.Next.In(london))
+	}
+	clock.Advance(21 * time.Hour) // 22:00 BST
+	if board.get() != 0x03 {
+		t.Fatalf("board %#x after sunset", board.get())
+	}
+	clock.Advance(6 * time.Hour) // 04:00 BST
+	if board.get() != 0x03 {
+		t.Fatalf("board %#x before sunrise", board.get())
+	}
+	clock.Advance(2 * time.Hour) // 06:00 BST
+	if board.get() != 0 {
+		t.Fatalf("board %#x after sunrise", board.get())
+	}
+	if off, _ := s.Job("lights.off"); off.Runs != 2 || !near(off.LastRun, time.Date(2021, 6, 2, 5, 3, 0, 0, london)) {
+		t.Fatalf("lights off ran %d times, last %v", off.Runs, off.LastRun.In(london))
+	}
+}
+
+// near reports whether t is within a minute of want.
+func near(t, want time.Time) bool {
+	d := t.Sub(want)
+	return d >= -time.Minute && d <= time.Minute
+}
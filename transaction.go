@@ -0,0 +1,122 @@
+package relay
+
+import (
+	"fmt"
+	"time"
+)
+
+// Transaction is a list of steps executed in order by Commit. If a step
+// fails, the board is switched back to the state it had before the
+// transaction.
+type Transaction struct {
+	client *Client
+	steps  []step
+}
+
+type step struct {
+	name string
+	run  func() error
+}
+
+// StepResult is the outcome of a single step of a transaction.
+type StepResult struct {
+	// Step describes the step, e.g. "on 3" or "wait 2s".
+	Step string
+	// Done is false for the steps after a failed one.
+	Done bool
+	Err  error
+}
+
+// TransactionReport describes the execution of a transaction.
+type TransactionReport struct {
+	// Before is the status read before the first step, BIT0 is channel 1.
+	Before uint32
+	Steps  []StepResult
+	// RolledBack is set when a step failed and the state was restored,
+	// RollbackErr when restoring failed too.
+	RolledBack  bool
+	RollbackErr error
+}
+
+// Transaction starts an empty transaction.
+func (c *Client) Transaction() *Transaction {
+	return &Transaction{client: c}
+}
+
+func (t *Transaction) add(name string, run func() error) *Transaction {
+	t.steps = append(t.steps, step{name: name, run: run})
+	return t
+}
+
+// On closes the channel.
+func (t *Transaction) On(ch Channel) *Transaction {
+	return t.add(fmt.Sprintf("on %d", ch), func() error {
+		return t.client.On(ch)
+	})
+}
+
+// Off opens the channel.
+func (t *Transaction) Off(ch Channel) *Transaction {
+	return t.add(fmt.Sprintf("off %d", ch), func() error {
+		return t.client.Off(ch)
+	})
+}
+
+// OnGroup closes the channels with a single group command.
+func (t *Transaction) OnGroup(chs ...Channel) *Transaction {
+	return t.add(fmt.Sprintf("on group %v", chs), func() error {
+		return t.client.OnChannels(chs...)
+	})
+}
+
+// OffGroup opens the channels with a single group command.
+func (t *Transaction) OffGroup(chs ...Channel) *Transaction {
+	return t.add(fmt.Sprintf("off group %v", chs), func() error {
+		return t.client.OffChannels(chs...)
+	})
+}
+
+// Pulse starts closing the channel for d; the next step does not wait for
+// the pulse to end.
+func (t *Transaction) Pulse(ch Channel, d time.Duration) *Transaction {
+	return t.add(fmt.Sprintf("pulse %d %v", ch, d), func() error {
+		_, err := t.client.Pulse(ch, d)
+		return err
+	})
+}
+
+// Wait delays the next step by d.
+func (t *Transaction) Wait(d time.Duration) *Transaction {
+	return t.add(fmt.Sprintf("wait %v", d), func() error {
+		t.client.clock.Sleep(d)
+		return nil
+	})
+}
+
+// Commit executes the steps in order. It stops at the first failing step,
+// switches all channels back to the state read before the first step and
+// returns the error of that step. The report lists the result of every
+// step either way.
+func (t *Transaction) Commit() (*TransactionReport, error) {
+	c := t.client
+	report := &TransactionReport{Steps: make([]StepResult, len(t.steps))}
+	for i, s := range t.steps {
+		report.Steps[i].Step = s.name
+	}
+	before, err := c.readStatus()
+	if err != nil {
+		return report, err
+	}
+	report.Before = before
+	for i, s := range t.steps {
+		err := s.run()
+		report.Steps[i].Done = err == nil
+		report.Steps[i].Err = err
+		if err != nil {
+			report.RollbackErr = c.SetAll(before)
+			report.RolledBack = report.RollbackErr == nil
+			return report, err
+		}
+	}
+	return report, nil
+}
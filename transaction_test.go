@@ -0,0 +1,61 @@
+package relay
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTransaction_Commit(t *testing.T) {
+	c, board := newTestClient(t, DefaultBranchesLength)
+	clock := newFakeClock()
+	c.SetClock(clock)
+	start := clock.Now()
+
+	report, err := c.Transaction().
+		On(3).
+		Wait(2 * time.Second).
+		OnGroup(1, 2).
+		OffGroup(2).
+		Pulse(8, time.Minute).
+		Commit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if board.get() != 0x85 || clock.Now().Sub(start) != 2*time.Second {
+		t.Fatalf("board %#x elapsed %v", board.get(), clock.Now().Sub(start))
+	}
+	if len(report.Steps) != 5 || report.Steps[1].Step != "wait 2s" || report.RolledBack {
+		t.Fatalf("report %+v", report)
+	}
+	for _, s := range report.Steps {
+		if !s.Done {
+			t.Fatalf("step %q not done", s.Step)
+		}
+	}
+}
+
+func TestTransaction_Rollback(t *testing.T) {
+	c, board := newTestClient(t, DefaultBranchesLength)
+	if err := c.On(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.AddInterlock(0, 2, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := c.Transaction().Off(1).On(2).On(3).On(4).Commit()
+	if err == nil {
+		t.Fatal("interlocked step succeeded")
+	}
+	if !report.RolledBack || report.Before != 0x01 || board.get() != 0x01 {
+		t.Fatalf("board %#x report %+v", board.get(), report)
+	}
+	for i, done := range []bool{true, true, false, false} {
+		if report.Steps[i].Done != done {
+			t.Fatalf("step %q done %v, want %v", report.Steps[i].Step, report.Steps[i].Done, done)
+		}
+	}
+	if report.Steps[2].Err != err || report.Steps[3].Err != nil {
+		t.Fatalf("report %+v", report)
+	}
+}
@@ -0,0 +1,71 @@
+package relay
+
+import (
+	"fmt"
+	"strings"
+)
+
+// VerifyError reports the channels whose read back state differs from the
+// state expected after fire-and-forget commands.
+type VerifyError struct {
+	// Expected is the state assumed by onNil, BIT0 is the first channel.
+	Expected uint32
+	// Actual is the state returned by RequestReadStatus.
+	Actual uint32
+	// Channels lists the mismatching channels.
+	Channels []Channel
+}
+
+func (e *VerifyError) Error() string {
+	channels := make([]string, len(e.Channels))
+	for k, v := range e.Channels {
+		channels[k] = fmt.Sprint(v)
+	}
+	return fmt.Sprintf("继电器状态校验失败,路数 %s: 期望 %#08x 实际 %#08x",
+		strings.Join(channels, ","), e.Expected, e.Actual)
+}
+
+// Verify reads the board status once and compares it with the cached state,
+// both under the client lock so no command can interleave. The cache is
+// replaced by the read back state; a *VerifyError lists the channels that
+// did not end up where the *Nil commands left them.
+func (c *Client) Verify() error {
+	c.Lock()
+	actual, err := c.readStatusLocked()
+	if err != nil {
+		c.Unlock()
+		return err
+	}
+	expected := c.cached()
+	c.setStat(actual)
+	c.unlock()
+
+	diff := (expected ^ actual) & c.full()
+	if diff == 0 {
+		return nil
+	}
+	e := &VerifyError{Expected: expected, Actual: actual & c.full()}
+	for ch := Channel(1); byte(ch) <= c.length; ch++ {
+		if diff&ch.bit() != 0 {
+			e.Channels = append(e.Channels, ch)
+		}
+	}
+	return e
+}
+
+// VerifyNil runs a batch of fire-and-forget commands and then verifies the
+// result with a single RequestReadStatus. Errors from the batch itself are
+// returned as is without reading the board.
+//
+//	err := client.VerifyNil(func() error {
+//		if err := client.OnNil(1); err != nil {
+//			return err
+//		}
+//		return client.OffChannelsNil(3, 4)
+//	})
+func (c *Client) VerifyNil(batch func() error) error {
+	if err := batch(); err != nil {
+		return err
+	}
+	return c.Verify()
+}
@@ -0,0 +1,61 @@
+package relay
+
+import (
+	"runtime"
+	"sync"
+	"testing"
+)
+
+func TestClient_VerifyNil(t *testing.T) {
+	c, board := newTestClient(t, DefaultBranchesLength)
+	err := c.VerifyNil(func() error {
+		if err := c.OnChannelsNil(1, 2, 3); err != nil {
+			return err
+		}
+		return c.FlipNil(5)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	board.drop = map[byte]bool{RequestOnGroupNil: true}
+	err = c.VerifyNil(func() error {
+		return c.OnChannelsNil(6, 7)
+	})
+	e, ok := err.(*VerifyError)
+	if !ok {
+		t.Fatalf("err %v, want *VerifyError", err)
+	}
+	if len(e.Channels) != 2 || e.Channels[0] != 6 || e.Channels[1] != 7 {
+		t.Fatalf("channels %v, want [6 7]", e.Channels)
+	}
+	if e.Actual != board.get() || c.GetStats()[5] != 0 {
+		t.Fatalf("cache not replaced by read back state: %v", c.GetStats())
+	}
+}
+
+func TestClient_VerifyConcurrent(t *testing.T) {
+	c, _ := newTestClient(t, DefaultBranchesLength)
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				_ = c.FlipNil(1)
+				runtime.Gosched()
+			}
+		}
+	}()
+	defer wg.Wait()
+	defer close(stop)
+	for i := 0; i < 500; i++ {
+		if err := c.Verify(); err != nil {
+			t.Fatalf("verify %d: %v", i, err)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package relay
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrWatchdogTimeout = errors.New("看门狗超时时间必须大于0")
+
+// Watchdog is a host side dead man's switch. When Kick is not called within
+// the timeout it switches its channels off with RequestOffGroup and keeps
+// retrying until the board confirms that all of them are off.
+type Watchdog struct {
+	client  *Client
+	chs     []Channel
+	mask    uint32
+	timeout time.Duration
+	retry   time.Duration
+
+	mu      sync.Mutex
+	timer   Timer
+	retrier Timer
+	tripped bool
+	stopped bool
+}
+
+// StartWatchdog starts a watchdog for the channels. retry is the interval
+// between attempts to switch the channels off, timeout is used if it is not
+// positive.
+func (c *Client) StartWatchdog(timeout, retry time.Duration, chs ...Channel) (*Watchdog, error) {
+	if timeout <= 0 {
+		return nil, ErrWatchdogTimeout
+	}
+	mask, err := c.mask(chs...)
+	if err != nil {
+		return nil, err
+	}
+	if retry <= 0 {
+		retry = timeout
+	}
+	w := &Watchdog{
+		client:  c,
+		chs:     append([]Channel(nil), chs...),
+		mask:    mask,
+		timeout: timeout,
+		retry:   retry,
+	}
+	c.Lock()
+	defer c.Unlock()
+	if c.closed {
+		return nil, ErrClientClosed
+	}
+	if c.watchdogs == nil {
+		c.watchdogs = make(map[*Watchdog]bool)
+	}
+	c.watchdogs[w] = true
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.timer = c.clock.AfterFunc(timeout, w.trip)
+	return w, nil
+}
+
+// Kick restarts the timeout. Kicking a tripped watchdog arms it again but
+// does not switch its channels back on.
+func (w *Watchdog) Kick() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.stopped {
+		return
+	}
+	w.tripped = false
+	w.timer.Reset(w.timeout)
+	if w.retrier != nil {
+		w.retrier.Stop()
+		w.retrier = nil
+	}
+}
+
+// Tripped reports whether the watchdog timed out since the last Kick.
+func (w *Watchdog) Tripped() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.tripped
+}
+
+// Stop disarms the watchdog and stops pending retries. Closing the client
+// stops all of its watchdogs.
+func (w *Watchdog) Stop() {
+	w.client.Lock()
+	delete(w.client.watchdogs, w)
+	w.client.Unlock()
+	w.halt()
+}
+
+// halt stops the timers. The client lock may be held by the caller.
+func (w *Watchdog) halt() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.stopped = true
+	w.timer.Stop()
+	if w.retrier != nil {
+		w.retrier.Stop()
+		w.retrier = nil
+	}
+}
+
+func (w *Watchdog) trip() {
+	w.mu.Lock()
+	if w.stopped {
+		w.mu.Unlock()
+		return
+	}
+	w.tripped = true
+	w.mu.Unlock()
+	w.client.notify(EventWatchdogTripped, 0, nil)
+	w.off()
+}
+
+// off switches the channels off and schedules a retry until confirmed or
+// until the watchdog is kicked or stopped.
+func (w *Watchdog) off() {
+	w.mu.Lock()
+	armed := w.tripped && !w.stopped
+	w.mu.Unlock()
+	if !armed {
+		return
+	}
+	status, err := w.client.offGroup(w.mask)
+	if err == nil && status&w.mask == 0 {
+		w.client.notify(EventWatchdogSafe, 0, nil)
+		return
+	}
+	if err == ErrClientClosed {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.tripped && !w.stopped {
+		w.retrier = w.client.clock.AfterFunc(w.retry, w.off)
+	}
+}
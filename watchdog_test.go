@@ -0,0 +1,103 @@
+package relay
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClient_Watchdog(t *testing.T) {
+	c, board := newTestClient(t, DefaultBranchesLength)
+	clock := newFakeClock()
+	c.SetClock(clock)
+	if err := c.OnChannels(1, 2, 3); err != nil {
+		t.Fatal(err)
+	}
+	w, err := c.StartWatchdog(time.Second, 100*time.Millisecond, 1, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+	for i := 0; i < 5; i++ {
+		clock.Advance(900 * time.Millisecond)
+		w.Kick()
+	}
+	if w.Tripped() || board.get() != 0x07 {
+		t.Fatalf("watchdog tripped while kicked, board %#x", board.get())
+	}
+
+	board.drop = map[byte]bool{RequestOffGroup: true}
+	clock.Advance(time.Second)
+	if !w.Tripped() || board.get() != 0x07 {
+		t.Fatalf("tripped %v board %#x", w.Tripped(), board.get())
+	}
+	clock.Advance(300 * time.Millisecond)
+	board.mu.Lock()
+	board.drop = nil
+	board.mu.Unlock()
+	clock.Advance(100 * time.Millisecond)
+	if board.get() != 0x02 {
+		t.Fatalf("board %#x, want channels 1 and 3 off", board.get())
+	}
+	n := len(board.frames)
+	clock.Advance(time.Second)
+	if len(board.frames) != n {
+		t.Fatal("watchdog kept retrying after the board confirmed")
+	}
+}
+
+func TestClient_WatchdogKickStopsRetry(t *testing.T) {
+	c, board := newTestClient(t, DefaultBranchesLength)
+	clock := newFakeClock()
+	c.SetClock(clock)
+	if err := c.On(1); err != nil {
+		t.Fatal(err)
+	}
+	w, err := c.StartWatchdog(time.Second, 100*time.Millisecond, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+	board.drop = map[byte]bool{RequestOffGroup: true}
+	clock.Advance(time.Second)
+	if !w.Tripped() {
+		t.Fatal("watchdog did not trip")
+	}
+	board.mu.Lock()
+	board.drop = nil
+	board.mu.Unlock()
+	w.Kick()
+	if err := c.On(1); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(500 * time.Millisecond)
+	if w.Tripped() || board.get() != 0x01 {
+		t.Fatalf("tripped %v board %#x, want the kick to stop retries", w.Tripped(), board.get())
+	}
+}
+
+func TestClient_WatchdogClose(t *testing.T) {
+	c, board := newTestClient(t, DefaultBranchesLength)
+	clock := newFakeClock()
+	c.SetClock(clock)
+	w, err := c.StartWatchdog(time.Second, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tripped := 0
+	c.SetEventHandler(func(e Event) {
+		if e.Kind == EventWatchdogTripped {
+			tripped++
+		}
+	})
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	n := len(board.frames)
+	clock.Advance(2 * time.Second)
+	if w.Tripped() || tripped != 0 || len(board.frames) != n {
+		t.Fatalf("watchdog tripped %d times after Close", tripped)
+	}
+	if _, err := c.StartWatchdog(time.Second, 0, 1); err != ErrClientClosed {
+		t.Fatalf("err %v, want %v", err, ErrClientClosed)
+	}
+}
@@ -0,0 +1,116 @@
+package relay
+
+import "time"
+
+// defaultWearWarn is the fraction of the rated cycles at which
+// EventWearWarning is emitted when WearLimit.Warn is not set.
+const defaultWearWarn = 0.9
+
+// WearLimit is the rated number of switching cycles of a relay.
+type WearLimit struct {
+	Rated uint64
+	// Warn is the fraction of Rated at which a warning is emitted first,
+	// 0.9 if zero.
+	Warn float64
+}
+
+// Wear reports the switching counters of a channel.
+type Wear struct {
+	Channel Channel
+	// Cycles counts the off to on transitions.
+	Cycles uint64
+	// OnTime is the cumulative time the channel was on.
+	OnTime time.Duration
+	Limit  WearLimit
+}
+
+// Remaining returns the cycles left until the rated cycles, zero if no limit
+// is set or it has been reached.
+func (w Wear) Remaining() uint64 {
+	if w.Cycles >= w.Limit.Rated {
+		return 0
+	}
+	return w.Limit.Rated - w.Cycles
+}
+
+// SetWearLimit sets the rated cycles of the channel.
+func (c *Client) SetWearLimit(ch Channel, limit WearLimit) error {
+	if err := c.check(ch); err != nil {
+		return err
+	}
+	if limit.Warn <= 0 || limit.Warn > 1 {
+		limit.Warn = defaultWearWarn
+	}
+	c.Lock()
+	defer c.Unlock()
+	c.rated[ch-1] = limit
+	return nil
+}
+
+// Wear returns the switching counters of the channel. The counters are
+// derived from every command that changes the state and are saved with it.
+func (c *Client) Wear(ch Channel) Wear {
+	if c.check(ch) != nil {
+		return Wear{Channel: ch}
+	}
+	c.Lock()
+	defer c.Unlock()
+	return c.wear(ch)
+}
+
+// WearReport returns the switching counters of all channels.
+func (c *Client) WearReport() []Wear {
+	c.Lock()
+	defer c.Unlock()
+	report := make([]Wear, c.length)
+	for k := range report {
+		report[k] = c.wear(Channel(k + 1))
+	}
+	return report
+}
+
+// ResetWear clears the counters of the channel, for example after the board
+// has been replaced.
+func (c *Client) ResetWear(ch Channel) error {
+	if err := c.check(ch); err != nil {
+		return err
+	}
+	c.Lock()
+	defer c.unlock()
+	c.cycles[ch-1] = 0
+	c.onTime[ch-1] = 0
+	if c.stat[ch-1] == 1 {
+		// the running on-time counts from now, onAt is kept for SetMaxOn
+		c.onTime[ch-1] = -c.clock.Now().Sub(c.onAt[ch-1])
+	}
+	return nil
+}
+
+// wear builds the counters of the channel. Caller must hold the lock.
+func (c *Client) wear(ch Channel) Wear {
+	i := ch - 1
+	w := Wear{
+		Channel: ch,
+		Cycles:  c.cycles[i],
+		OnTime:  c.onTime[i],
+		Limit:   c.rated[i],
+	}
+	if c.stat[i] == 1 {
+		w.OnTime += c.clock.Now().Sub(c.onAt[i])
+	}
+	return w
+}
+
+// cycle counts an off to on transition. Caller must hold the lock.
+func (c *Client) cycle(ch Channel) {
+	i := ch - 1
+	c.cycles[i]++
+	limit := c.rated[i]
+	if limit.Rated == 0 {
+		return
+	}
+	warn := uint64(float64(limit.Rated) * limit.Warn)
+	if c.cycles[i] == warn || c.cycles[i] == limit.Rated {
+		c.emit(EventWearWarning, ch, nil)
+	}
+}
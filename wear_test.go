@@ -0,0 +1,98 @@
+package relay
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestClient_Wear(t *testing.T) {
+	c, _ := newTestClient(t, DefaultBranchesLength)
+	clock := newFakeClock()
+	c.SetClock(clock)
+	var warnings int
+	c.SetEventHandler(func(e Event) {
+		if e.Kind == EventWearWarning && e.Channel == 1 {
+			warnings++
+		}
+	})
+	if err := c.SetWearLimit(1, WearLimit{Rated: 8, Warn: 0.5}); err != nil {
+		t.Fatal(err)
+	}
+	steps := []func() error{
+		func() error { return c.On(1) },
+		c.OffAll,
+		func() error { return c.OnNil(1) },
+		func() error { return c.FlipChannels(1, 2) },
+		func() error { return c.FlipChannelsNil(1) },
+		func() error { _, err := c.Pulse(1, time.Second); return err },
+	}
+	for _, step := range steps {
+		if err := step(); err != nil {
+			t.Fatal(err)
+		}
+		clock.Advance(time.Second)
+	}
+	// the pulse starts while channel 1 is on and ends it pointAdvance early
+	w := c.Wear(1)
+	if w.Cycles != 3 || w.OnTime != 4*time.Second-pointAdvance {
+		t.Fatalf("wear %+v, want 3 cycles", w)
+	}
+	if c.Wear(2).Cycles != 1 || c.Wear(2).OnTime != 3*time.Second {
+		t.Fatalf("wear %+v, want 1 cycle", c.Wear(2))
+	}
+	if err := c.SetAll(0x01); err != nil {
+		t.Fatal(err)
+	}
+	if warnings != 1 || c.Wear(1).Remaining() != 4 {
+		t.Fatalf("warnings %d remaining %d", warnings, c.Wear(1).Remaining())
+	}
+	if err := c.ResetWear(1); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Second)
+	if w := c.Wear(1); w.Cycles != 0 || w.OnTime != time.Second {
+		t.Fatalf("wear %+v after reset", w)
+	}
+}
+
+func TestClient_RestoreWear(t *testing.T) {
+	store := NewFileStore(filepath.Join(t.TempDir(), "relay.json"))
+	clock := newFakeClock()
+	c, _ := newTestClient(t, DefaultBranchesLength)
+	c.SetClock(clock)
+	c.SetStateStore(store)
+	if err := c.On(1); err != nil {
+		t.Fatal(err)
+	}
+	restart := func(policy RestorePolicy) {
+		t.Helper()
+		var board *fakeBoard
+		c, board = newTestClient(t, DefaultBranchesLength)
+		if policy == AdoptHardware {
+			board.state = 0x01
+		}
+		c.SetClock(clock)
+		c.SetStateStore(store)
+		if err := c.Restore(policy); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, policy := range []RestorePolicy{AdoptHardware, RestoreCommanded, AdoptHardware} {
+		clock.Advance(time.Minute)
+		restart(policy)
+		if w := c.Wear(1); w.Cycles != 1 {
+			t.Fatalf("policy %d: cycles %d, want restarts not counted", policy, w.Cycles)
+		}
+	}
+
+	// a reset while the channel is on survives a restart
+	if err := c.ResetWear(1); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Minute)
+	restart(AdoptHardware)
+	if w := c.Wear(1); w.Cycles != 0 || w.OnTime != time.Minute {
+		t.Fatalf("wear %+v, want the reset kept", w)
+	}
+}